@@ -0,0 +1,16 @@
+# check whether an acl instance already allows access to an app
+data "acl_destination_rule" "app" {
+  instance = "<< ACL INSTANCE >>"
+
+  app = "<< DESTINATION-APP >>"
+}
+
+output "app_rule_id" {
+  value = data.acl_destination_rule.app.rule_id
+}
+
+# the id has the acl_destination_rule resource ID format, it can be used to
+# import the rule
+output "app_rule_import_id" {
+  value = data.acl_destination_rule.app.id
+}
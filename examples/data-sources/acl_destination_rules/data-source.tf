@@ -0,0 +1,15 @@
+# every rule of an acl instance
+data "acl_destination_rules" "all" {
+  instance = "<< ACL INSTANCE >>"
+}
+
+# only external destinations
+data "acl_destination_rules" "external" {
+  instance = "<< ACL INSTANCE >>"
+
+  destination_types = ["ip", "dns"]
+}
+
+output "external_rule_ids" {
+  value = data.acl_destination_rules.external.rules[*].rule_id
+}
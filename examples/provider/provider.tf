@@ -0,0 +1,7 @@
+provider "acl" {
+  # stamped on every rule created by this provider
+  default_metadata = {
+    managed_by = "terraform"
+    repo       = "<< REPOSITORY >>"
+  }
+}
@@ -1,23 +1,55 @@
+# by rule ID, the format of the resource ID (also used by import blocks)
+terraform import acl_destination_rule.resource_name "service::instance::rule-id"
+
+# example
+terraform import acl_destination_rule.my_acl_rule "acl::my-acl::5f8e2c1a9d3b4e0012a3b4c5"
+
 # for app
 terraform import acl_destination_rule.resource_name "service::acl::instance::app::app-name"
 
 # example
 terraform import acl_destination_rule.my_acl_app "acl-rule::acl::my-acl::app::sample-app"
 
+# for pool, only rules with the pool as destination match (app rules never do)
+terraform import acl_destination_rule.resource_name "service::acl::instance::pool::pool-name"
+
+# example
+terraform import acl_destination_rule.my_acl_pool "acl-rule::acl::my-acl::pool::sample-pool"
+
 # for dns
 terraform import acl_destination_rule.resource_name "service::acl::instance::dns::dns-name"
 
 # example
 terraform import acl_destination_rule.my_acl_dns "acl-rule::acl::my-acl::dns::example.com"
 
+# when more than one rule has the same dns (or ip), add its ports
+terraform import acl_destination_rule.my_acl_dns "acl-rule::acl::my-acl::dns::example.com::TCP:80,TCP:443"
+
 # for ip
 terraform import acl_destination_rule.resource_name "service::acl::instance::ip::cidr-target"
 
 # example
 terraform import acl_destination_rule.my_acl_ip "acl-rule::acl::my-acl::ip::10.0.0.1/24"
 
+# IPv6 destinations are written as is, even though they contain "::", ports
+# can still be added after them
+terraform import acl_destination_rule.my_acl_ipv6 "acl-rule::acl::my-acl::ip::2001:db8::/32::TCP:443"
+
 # for rpaas
 terraform import acl_destination_rule.resource_name "service::acl::instance::rpaas::service-instance::instance-rpaas"
 
 # example for rpaasv2-be
 terraform import acl_destination_rule.my_acl_rpaas "acl-rule::acl::my-acl::rpaas::rpaasv2-be::sample-app-rpaas"
+
+# for kubernetes service, cluster is optional (new kubernetes service rules
+# are rejected by acl-api, existing ones can still be imported)
+terraform import acl_destination_rule.resource_name "service::acl::instance::kubernetes_service::namespace::service-name::cluster"
+
+# example
+terraform import acl_destination_rule.my_acl_k8s "acl-rule::acl::my-acl::kubernetes_service::default::my-service::my-cluster"
+
+# by rule name
+terraform import acl_destination_rule.resource_name "service::acl::instance::name::rule-name"
+
+# example
+terraform import acl_destination_rule.my_acl_named "acl-rule::acl::my-acl::name::example-org"
@@ -20,6 +20,12 @@ resource "acl_destination_rule" "test_app" {
   instance = tsuru_service_instance.acl.name
 
   app = "<< DESTINATION-APP >>"
+
+  # optional, waits longer for tsuru event locks during deploys
+  timeouts {
+    create = "20m"
+    delete = "20m"
+  }
 }
 
 
@@ -27,7 +33,7 @@ resource "acl_destination_rule" "test_app" {
 resource "acl_destination_rule" "test_app" {
   instance = tsuru_service_instance.acl.name
 
-  rpaas {
+  rpaas = {
     service_name = "<< DESTINATION-RPAAS-SERVICE >>"
     instance     = "<< DESTINATION-RPAAS-INSTANCE >>"
   }
@@ -37,12 +43,22 @@ resource "acl_destination_rule" "test_app" {
 resource "acl_destination_rule" "test_app" {
   instance = tsuru_service_instance.acl.name
 
+  # optional, helps to find the rule in tsuru ACL listings
+  name = "example-org"
+  metadata = {
+    repository = "<< REPOSITORY >>"
+    ticket     = "<< TICKET >>"
+  }
+
+  # wildcard domains are written as "*.example.org"
   dns = "example.org"
 
-  port {
-    number   = 80
-    protocol = "TCP"
-  }
+  port = [
+    {
+      number   = 80
+      protocol = "TCP"
+    },
+  ]
 }
 
 
@@ -52,13 +68,20 @@ resource "acl_destination_rule" "test_app" {
 
   ip = "<< NETWORK CIDR >>"
 
-  port {
-    number   = 80
-    protocol = "TCP"
-  }
-
-  port {
-    number   = 443
-    protocol = "TCP"
-  }
+  port = [
+    {
+      number   = 80
+      protocol = "TCP"
+    },
+    {
+      number   = 443
+      protocol = "TCP"
+    },
+    # a port range, ex: passive FTP
+    {
+      from     = 30000
+      to       = 30100
+      protocol = "TCP"
+    },
+  ]
 }
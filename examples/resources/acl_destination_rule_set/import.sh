@@ -0,0 +1,5 @@
+# every rule of the instance is imported into the set
+terraform import acl_destination_rule_set.resource_name "service::instance"
+
+# example
+terraform import acl_destination_rule_set.my_acl "acl::my-acl"
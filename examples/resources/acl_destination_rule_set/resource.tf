@@ -0,0 +1,35 @@
+# every egress rule of an acl instance in a single resource
+resource "acl_destination_rule_set" "app" {
+  instance = tsuru_service_instance.acl.name
+
+  # remove rules created outside terraform
+  exclusive = true
+
+  rule {
+    app = "<< DESTINATION-APP >>"
+  }
+
+  rule {
+    rpaas {
+      service_name = "<< DESTINATION-RPAAS-SERVICE >>"
+      instance     = "<< DESTINATION-RPAAS-INSTANCE >>"
+    }
+  }
+
+  rule {
+    dns = "example.org"
+
+    port {
+      number   = 443
+      protocol = "TCP"
+    }
+  }
+
+  # optional, rules are created and removed one by one, each waiting for tsuru
+  # event locks (defaults: 10m)
+  timeouts {
+    create = "20m"
+    update = "20m"
+    delete = "20m"
+  }
+}
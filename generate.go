@@ -0,0 +1,47 @@
+// Copyright 2021 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"io"
+
+	"github.com/tsuru/terraform-provider-acl/internal/acl"
+	"github.com/tsuru/terraform-provider-acl/internal/provider"
+)
+
+// runGenerate implements the generate subcommand, printing the Terraform
+// configuration and import blocks for every rule of an ACL instance.
+func runGenerate(ctx context.Context, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("generate", flag.ContinueOnError)
+	serviceName := flags.String("service", "acl", "ACL service name")
+	instance := flags.String("instance", "", "ACL service instance name (required)")
+	host := flags.String("host", "", "target to tsuru API, defaults to the tsuru client target")
+	token := flags.String("token", "", "tsuru API token, defaults to the tsuru client token")
+	skipCertVerification := flags.Bool("skip-cert-verification", false, "disable TLS certificate verification")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if len(*instance) == 0 {
+		return errors.New("-instance is required")
+	}
+
+	cli, err := acl.NewClient(ctx, *host, *token, acl.ClientOptions{
+		SkipCertVerification: *skipCertVerification,
+		Retry:                acl.DefaultRetryPolicy,
+	})
+	if err != nil {
+		return err
+	}
+
+	rules, err := cli.DestinationRules(ctx, *serviceName, *instance)
+	if err != nil {
+		return err
+	}
+
+	return provider.GenerateConfig(out, *serviceName, *instance, rules)
+}
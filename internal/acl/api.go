@@ -1,20 +1,55 @@
 package acl
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"log"
 	"net/http"
 	"strings"
-
-	"github.com/pkg/errors"
+	"time"
 )
 
 const userAgent = "Terraform-Provider-ACL"
 
 func doProxyURLRequest(ctx context.Context, method, fullUrl string, body io.Reader, cli *clientImpl) (*http.Response, error) {
+	var data []byte
+	if body != nil {
+		var err error
+		data, err = io.ReadAll(body)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for attempt := 1; ; attempt++ {
+		var reqBody io.Reader
+		if body != nil {
+			reqBody = bytes.NewReader(data)
+		}
+
+		rsp, err := doURLRequest(ctx, method, fullUrl, reqBody, cli)
+		if err == nil || attempt >= cli.retry.MaxAttempts || !isTransientError(method, err) {
+			return rsp, err
+		}
+
+		wait := cli.retry.backoff(attempt, err)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+			// the next attempt would start after the deadline
+			return nil, err
+		}
+		log.Printf("[DEBUG] request to %s failed (attempt %d/%d), retrying in %s: %s", fullUrl, attempt, cli.retry.MaxAttempts, wait, err)
+
+		select {
+		case <-ctx.Done():
+			return nil, err
+		case <-time.After(wait):
+		}
+	}
+}
+
+func doURLRequest(ctx context.Context, method, fullUrl string, body io.Reader, cli *clientImpl) (*http.Response, error) {
 	req, err := http.NewRequestWithContext(ctx, method, fullUrl, body)
 	if err != nil {
 		return nil, err
@@ -24,14 +59,14 @@ func doProxyURLRequest(ctx context.Context, method, fullUrl string, body io.Read
 	}
 	req.Header.Set("Authorization", "bearer "+cli.token)
 	req.Header.Set("User-Agent", userAgent)
-	rsp, err := http.DefaultClient.Do(req)
+	rsp, err := cli.httpClient.Do(req)
 	if err != nil {
 		return nil, err
 	}
 	if rsp.StatusCode < 200 || rsp.StatusCode >= 400 {
-		data, _ := ioutil.ReadAll(rsp.Body)
+		data, _ := io.ReadAll(rsp.Body)
 		rsp.Body.Close()
-		return nil, errors.Errorf("invalid status code %d: %q", rsp.StatusCode, string(data))
+		return nil, newAPIError(req, rsp, data)
 	}
 
 	return rsp, nil
@@ -0,0 +1,93 @@
+package acl
+
+import (
+	"context"
+	"sync"
+
+	"github.com/tsuru/acl-api/api/types"
+)
+
+// CachedClient wraps a Client keeping the rules of each service instance in
+// memory, so refreshing many resources of the same instance makes a single
+// request. Concurrent calls for the same instance share the in-flight request
+// and any create or delete on an instance invalidates its entry.
+type CachedClient struct {
+	Client
+
+	mu      sync.Mutex
+	entries map[string]*rulesCall
+}
+
+type rulesCall struct {
+	done  chan struct{}
+	rules []types.Rule
+	err   error
+}
+
+var _ Client = &CachedClient{}
+
+func NewCachedClient(cli Client) *CachedClient {
+	return &CachedClient{
+		Client:  cli,
+		entries: map[string]*rulesCall{},
+	}
+}
+
+func (c *CachedClient) DestinationRuleCreate(ctx context.Context, serviceName, instance string, rule *types.Rule) error {
+	defer c.Invalidate(serviceName, instance)
+	return c.Client.DestinationRuleCreate(ctx, serviceName, instance, rule)
+}
+
+func (c *CachedClient) DestinationRuleDelete(ctx context.Context, ruleID, serviceName, instance string) error {
+	defer c.Invalidate(serviceName, instance)
+	return c.Client.DestinationRuleDelete(ctx, ruleID, serviceName, instance)
+}
+
+func (c *CachedClient) DestinationRules(ctx context.Context, serviceName, instance string) ([]types.Rule, error) {
+	key := GenerateID([]string{serviceName, instance})
+
+	c.mu.Lock()
+	call, ok := c.entries[key]
+	if !ok {
+		call = &rulesCall{done: make(chan struct{})}
+		c.entries[key] = call
+		c.mu.Unlock()
+
+		call.rules, call.err = c.Client.DestinationRules(ctx, serviceName, instance)
+		if call.err != nil {
+			c.forget(key, call)
+		}
+		close(call.done)
+	} else {
+		c.mu.Unlock()
+	}
+
+	select {
+	case <-call.done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	if call.err != nil {
+		return nil, call.err
+	}
+
+	rules := make([]types.Rule, len(call.rules))
+	copy(rules, call.rules)
+	return rules, nil
+}
+
+// Invalidate drops the cached rules of a service instance.
+func (c *CachedClient) Invalidate(serviceName, instance string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, GenerateID([]string{serviceName, instance}))
+}
+
+func (c *CachedClient) forget(key string, call *rulesCall) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries[key] == call {
+		delete(c.entries, key)
+	}
+}
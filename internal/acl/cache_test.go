@@ -0,0 +1,103 @@
+// Copyright 2021 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package acl
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tsuru/acl-api/api/types"
+)
+
+type fakeClient struct {
+	calls int32
+	err   error
+	delay time.Duration
+}
+
+func (f *fakeClient) DestinationRuleCreate(ctx context.Context, serviceName, instance string, rule *types.Rule) error {
+	return nil
+}
+
+func (f *fakeClient) DestinationRules(ctx context.Context, serviceName, instance string) ([]types.Rule, error) {
+	atomic.AddInt32(&f.calls, 1)
+	time.Sleep(f.delay)
+	if f.err != nil {
+		return nil, f.err
+	}
+	return []types.Rule{{RuleID: serviceName + "-" + instance}}, nil
+}
+
+func (f *fakeClient) DestinationRuleDelete(ctx context.Context, ruleID, serviceName, instance string) error {
+	return nil
+}
+
+func (f *fakeClient) DestinationRuleSyncStatus(ctx context.Context, ruleID, serviceName, instance string) (*RuleSyncStatus, error) {
+	return &RuleSyncStatus{}, nil
+}
+
+func TestCachedClientCoalescesRequests(t *testing.T) {
+	fake := &fakeClient{delay: 50 * time.Millisecond}
+	cli := NewCachedClient(fake)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rules, err := cli.DestinationRules(context.Background(), "acl", "my-acl")
+			assert.NoError(t, err)
+			assert.Equal(t, []types.Rule{{RuleID: "acl-my-acl"}}, rules)
+		}()
+	}
+	wg.Wait()
+	require.Equal(t, int32(1), atomic.LoadInt32(&fake.calls))
+
+	_, err := cli.DestinationRules(context.Background(), "acl", "other-acl")
+	require.NoError(t, err)
+	require.Equal(t, int32(2), atomic.LoadInt32(&fake.calls))
+}
+
+func TestCachedClientInvalidatesOnChange(t *testing.T) {
+	fake := &fakeClient{}
+	cli := NewCachedClient(fake)
+	ctx := context.Background()
+
+	_, err := cli.DestinationRules(ctx, "acl", "my-acl")
+	require.NoError(t, err)
+	_, err = cli.DestinationRules(ctx, "acl", "my-acl")
+	require.NoError(t, err)
+	require.Equal(t, int32(1), fake.calls)
+
+	require.NoError(t, cli.DestinationRuleCreate(ctx, "acl", "my-acl", &types.Rule{}))
+	_, err = cli.DestinationRules(ctx, "acl", "my-acl")
+	require.NoError(t, err)
+	require.Equal(t, int32(2), fake.calls)
+
+	require.NoError(t, cli.DestinationRuleDelete(ctx, "my-rule", "acl", "my-acl"))
+	_, err = cli.DestinationRules(ctx, "acl", "my-acl")
+	require.NoError(t, err)
+	require.Equal(t, int32(3), fake.calls)
+}
+
+func TestCachedClientDoesNotCacheErrors(t *testing.T) {
+	fake := &fakeClient{err: errors.New("boom")}
+	cli := NewCachedClient(fake)
+
+	_, err := cli.DestinationRules(context.Background(), "acl", "my-acl")
+	require.EqualError(t, err, "boom")
+
+	fake.err = nil
+	rules, err := cli.DestinationRules(context.Background(), "acl", "my-acl")
+	require.NoError(t, err)
+	require.Len(t, rules, 1)
+	require.Equal(t, int32(2), fake.calls)
+}
@@ -2,6 +2,7 @@ package acl
 
 import (
 	"context"
+	"net/http"
 
 	"github.com/tsuru/acl-api/api/types"
 	tsuruclient "github.com/tsuru/go-tsuruclient/pkg/client"
@@ -12,14 +13,17 @@ type Client interface {
 	DestinationRuleCreate(ctx context.Context, serviceName, instance string, rule *types.Rule) error
 	DestinationRules(ctx context.Context, serviceName, instance string) (rules []types.Rule, err error)
 	DestinationRuleDelete(ctx context.Context, ruleID, serviceName, instance string) error
+	DestinationRuleSyncStatus(ctx context.Context, ruleID, serviceName, instance string) (*RuleSyncStatus, error)
 }
 
 type clientImpl struct {
-	Host  string
-	token string
+	Host       string
+	token      string
+	httpClient *http.Client
+	retry      RetryPolicy
 }
 
-func NewClient(ctx context.Context, host, token string) (Client, error) {
+func NewClient(ctx context.Context, host, token string, opts ClientOptions) (Client, error) {
 	if len(host) == 0 {
 		target, err := config.GetTarget()
 		if err != nil {
@@ -36,9 +40,16 @@ func NewClient(ctx context.Context, host, token string) (Client, error) {
 		token = tsuruToken
 	}
 
+	httpClient, err := newHTTPClient(opts)
+	if err != nil {
+		return nil, err
+	}
+
 	return &clientImpl{
-		Host:  host,
-		token: token,
+		Host:       host,
+		token:      token,
+		httpClient: httpClient,
+		retry:      opts.Retry,
 	}, nil
 }
 
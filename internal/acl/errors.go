@@ -0,0 +1,90 @@
+package acl
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// APIError is returned when tsuru API, or acl-api through the service proxy,
+// answers a request with a non successful status code.
+type APIError struct {
+	StatusCode int
+	Method     string
+	Path       string
+	Body       string
+
+	// Message is the error message parsed from Body.
+	Message string
+
+	// RetryAfter is the delay requested by the server through the
+	// Retry-After header.
+	RetryAfter time.Duration
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s %s: invalid status code %d: %q", e.Method, e.Path, e.StatusCode, e.Message)
+}
+
+func newAPIError(req *http.Request, rsp *http.Response, body []byte) *APIError {
+	return &APIError{
+		StatusCode: rsp.StatusCode,
+		Method:     req.Method,
+		Path:       req.URL.RequestURI(),
+		Body:       string(body),
+		Message:    parseErrorMessage(body),
+		RetryAfter: parseRetryAfter(rsp.Header.Get("Retry-After")),
+	}
+}
+
+func parseErrorMessage(body []byte) string {
+	var data struct {
+		Message      string `json:"message"`
+		ErrorMessage string `json:"error"`
+	}
+	if err := json.Unmarshal(body, &data); err == nil {
+		if len(data.Message) > 0 {
+			return data.Message
+		}
+		if len(data.ErrorMessage) > 0 {
+			return data.ErrorMessage
+		}
+	}
+
+	return strings.TrimSpace(string(body))
+}
+
+func statusCode(err error) int {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode
+	}
+	return 0
+}
+
+func IsNotFound(err error) bool {
+	return statusCode(err) == http.StatusNotFound
+}
+
+func IsConflict(err error) bool {
+	return statusCode(err) == http.StatusConflict
+}
+
+func IsUnauthorized(err error) bool {
+	code := statusCode(err)
+	return code == http.StatusUnauthorized || code == http.StatusForbidden
+}
+
+// IsLocked reports whether the request failed because tsuru holds a lock on
+// the service instance, usually released after a few seconds.
+func IsLocked(err error) bool {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	return strings.Contains(apiErr.Message, "event locked")
+}
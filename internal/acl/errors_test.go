@@ -0,0 +1,85 @@
+// Copyright 2021 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package acl
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPIError(t *testing.T) {
+	tests := []struct {
+		name         string
+		status       int
+		body         string
+		message      string
+		notFound     bool
+		conflict     bool
+		locked       bool
+		unauthorized bool
+	}{
+		{
+			name:     "instance not found",
+			status:   http.StatusNotFound,
+			body:     "service instance not found\n",
+			message:  "service instance not found",
+			notFound: true,
+		},
+		{
+			name:     "event locked",
+			status:   http.StatusConflict,
+			body:     "event locked: service-instance(acl/my-acl) running \"service-instance.update\"",
+			message:  "event locked: service-instance(acl/my-acl) running \"service-instance.update\"",
+			conflict: true,
+			locked:   true,
+		},
+		{
+			name:     "rule already exists",
+			status:   http.StatusConflict,
+			body:     `{"message":"rule already exists"}`,
+			message:  "rule already exists",
+			conflict: true,
+		},
+		{
+			name:         "unauthorized",
+			status:       http.StatusUnauthorized,
+			body:         "invalid token",
+			message:      "invalid token",
+			unauthorized: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			cli := &clientImpl{Host: server.URL, httpClient: server.Client()}
+			_, err := doProxyRequest(context.Background(), http.MethodGet, "acl", "my-acl", "/rule", nil, cli)
+			require.Error(t, err)
+
+			var apiErr *APIError
+			require.True(t, errors.As(errors.Wrap(err, "wrapped"), &apiErr))
+			require.Equal(t, tt.status, apiErr.StatusCode)
+			require.Equal(t, http.MethodGet, apiErr.Method)
+			require.Equal(t, "/services/acl/proxy/my-acl?callback=/rule", apiErr.Path)
+			require.Equal(t, tt.body, apiErr.Body)
+			require.Equal(t, tt.message, apiErr.Message)
+
+			require.Equal(t, tt.notFound, IsNotFound(err))
+			require.Equal(t, tt.conflict, IsConflict(err))
+			require.Equal(t, tt.locked, IsLocked(err))
+			require.Equal(t, tt.unauthorized, IsUnauthorized(err))
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package acl
+
+import (
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// RetryPolicy configures how requests failing with transient errors are
+// retried.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts of a request, values
+	// lower than 2 disable retries.
+	MaxAttempts int
+	MinBackoff  time.Duration
+	MaxBackoff  time.Duration
+}
+
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 4,
+	MinBackoff:  time.Second,
+	MaxBackoff:  30 * time.Second,
+}
+
+var retryableStatusCodes = map[int]bool{
+	http.StatusTooManyRequests:    true,
+	http.StatusBadGateway:         true,
+	http.StatusServiceUnavailable: true,
+	http.StatusGatewayTimeout:     true,
+}
+
+// notAppliedStatusCodes are the status codes meaning the request was refused
+// before being processed, so even non idempotent requests can be retried.
+var notAppliedStatusCodes = map[int]bool{
+	http.StatusTooManyRequests:    true,
+	http.StatusServiceUnavailable: true,
+}
+
+// isTransientError reports whether a failed request is worth retrying. A
+// non idempotent request (POST) is only retried when it is known not to have
+// been applied, otherwise a rule could be created twice.
+func isTransientError(method string, err error) bool {
+	idempotent := method != http.MethodPost
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		if !idempotent {
+			return notAppliedStatusCodes[apiErr.StatusCode]
+		}
+		return retryableStatusCodes[apiErr.StatusCode]
+	}
+
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		// the connection was never established
+		return true
+	}
+
+	if !idempotent {
+		return false
+	}
+
+	return errors.Is(err, syscall.ECONNRESET) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// backoff returns how long to wait before the next attempt, honoring the
+// Retry-After sent by the server, up to MaxBackoff, or else using an
+// exponential backoff with jitter between MinBackoff and MaxBackoff.
+func (p RetryPolicy) backoff(attempt int, err error) time.Duration {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.RetryAfter > 0 {
+		if p.MaxBackoff > 0 && apiErr.RetryAfter > p.MaxBackoff {
+			return p.MaxBackoff
+		}
+		return apiErr.RetryAfter
+	}
+
+	backoff := p.MinBackoff
+	for i := 1; i < attempt && backoff < p.MaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > p.MaxBackoff {
+		backoff = p.MaxBackoff
+	}
+	if backoff <= 0 {
+		return 0
+	}
+
+	half := backoff / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+func parseRetryAfter(value string) time.Duration {
+	if len(value) == 0 {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		if wait := time.Until(date); wait > 0 {
+			return wait
+		}
+	}
+
+	return 0
+}
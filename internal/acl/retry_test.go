@@ -0,0 +1,176 @@
+// Copyright 2021 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package acl
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDoProxyRequestRetries(t *testing.T) {
+	tests := []struct {
+		name          string
+		method        string
+		statuses      []int
+		retryAfter    string
+		expectedCalls int
+		expectedErr   bool
+	}{
+		{
+			name:          "succeeds after transient failures",
+			method:        http.MethodDelete,
+			statuses:      []int{http.StatusServiceUnavailable, http.StatusBadGateway, http.StatusOK},
+			expectedCalls: 3,
+		},
+		{
+			name:          "honors retry after",
+			method:        http.MethodPost,
+			statuses:      []int{http.StatusTooManyRequests, http.StatusOK},
+			retryAfter:    "1",
+			expectedCalls: 2,
+		},
+		{
+			name:          "gives up after max attempts",
+			method:        http.MethodDelete,
+			statuses:      []int{http.StatusGatewayTimeout, http.StatusGatewayTimeout, http.StatusGatewayTimeout, http.StatusOK},
+			expectedCalls: 3,
+			expectedErr:   true,
+		},
+		{
+			name:          "does not retry client errors",
+			method:        http.MethodDelete,
+			statuses:      []int{http.StatusBadRequest, http.StatusOK},
+			expectedCalls: 1,
+			expectedErr:   true,
+		},
+		{
+			name:          "retries post refused before processing",
+			method:        http.MethodPost,
+			statuses:      []int{http.StatusServiceUnavailable, http.StatusOK},
+			expectedCalls: 2,
+		},
+		{
+			name:          "does not retry post possibly applied",
+			method:        http.MethodPost,
+			statuses:      []int{http.StatusBadGateway, http.StatusOK},
+			expectedCalls: 1,
+			expectedErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				assert.Equal(t, `{"RuleID":""}`, string(body))
+
+				if len(tt.retryAfter) > 0 {
+					w.Header().Set("Retry-After", tt.retryAfter)
+				}
+				w.WriteHeader(tt.statuses[calls])
+				calls++
+			}))
+			defer server.Close()
+
+			cli := &clientImpl{
+				Host:       server.URL,
+				httpClient: server.Client(),
+				retry: RetryPolicy{
+					MaxAttempts: 3,
+					MinBackoff:  time.Millisecond,
+					MaxBackoff:  2 * time.Second,
+				},
+			}
+
+			start := time.Now()
+			rsp, err := doProxyRequest(context.Background(), tt.method, "acl", "my-acl", "/rule", strings.NewReader(`{"RuleID":""}`), cli)
+			if tt.expectedErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				rsp.Body.Close()
+			}
+			require.Equal(t, tt.expectedCalls, calls)
+
+			if len(tt.retryAfter) > 0 {
+				require.GreaterOrEqual(t, time.Since(start), time.Second)
+			}
+		})
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := RetryPolicy{
+		MaxAttempts: 10,
+		MinBackoff:  100 * time.Millisecond,
+		MaxBackoff:  time.Second,
+	}
+
+	for attempt := 1; attempt < 10; attempt++ {
+		backoff := policy.backoff(attempt, nil)
+		require.GreaterOrEqual(t, backoff, policy.MinBackoff/2)
+		require.LessOrEqual(t, backoff, policy.MaxBackoff)
+	}
+
+	require.LessOrEqual(t, policy.backoff(1, nil), 100*time.Millisecond)
+	require.GreaterOrEqual(t, policy.backoff(9, nil), 500*time.Millisecond)
+	require.Equal(t, 500*time.Millisecond, policy.backoff(1, &APIError{RetryAfter: 500 * time.Millisecond}))
+	require.Equal(t, policy.MaxBackoff, policy.backoff(1, &APIError{RetryAfter: 5 * time.Second}))
+}
+
+func TestIsTransientError(t *testing.T) {
+	dialErr := &url.Error{Op: "Post", URL: "http://localhost", Err: &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}}
+	resetErr := &url.Error{Op: "Post", URL: "http://localhost", Err: &net.OpError{Op: "read", Err: syscall.ECONNRESET}}
+
+	require.True(t, isTransientError(http.MethodGet, &APIError{StatusCode: http.StatusBadGateway}))
+	require.True(t, isTransientError(http.MethodGet, resetErr))
+	require.True(t, isTransientError(http.MethodGet, io.EOF))
+	require.True(t, isTransientError(http.MethodPost, &APIError{StatusCode: http.StatusTooManyRequests}))
+	require.True(t, isTransientError(http.MethodPost, dialErr))
+	require.False(t, isTransientError(http.MethodPost, &APIError{StatusCode: http.StatusGatewayTimeout}))
+	require.False(t, isTransientError(http.MethodPost, resetErr))
+	require.False(t, isTransientError(http.MethodPost, io.EOF))
+}
+
+func TestDoProxyRequestStopsAtDeadline(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "10")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		calls++
+	}))
+	defer server.Close()
+
+	cli := &clientImpl{
+		Host:       server.URL,
+		httpClient: server.Client(),
+		retry: RetryPolicy{
+			MaxAttempts: 3,
+			MinBackoff:  time.Millisecond,
+			MaxBackoff:  time.Minute,
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	start := time.Now()
+	_, err := doProxyRequest(ctx, http.MethodGet, "acl", "my-acl", "/rule", nil, cli)
+	require.Error(t, err)
+	require.Equal(t, 1, calls)
+	require.Less(t, time.Since(start), time.Second)
+}
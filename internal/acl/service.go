@@ -4,7 +4,11 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
+	"net"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/pkg/errors"
 	"github.com/tsuru/acl-api/api/types"
@@ -68,6 +72,10 @@ func (cli *clientImpl) DestinationRules(ctx context.Context, serviceName, instan
 	}
 
 	for _, rule := range ruleData.ServiceInstance.BaseRules {
+		// ServiceRule.Creator shadows Rule.Creator when decoding
+		if len(rule.Rule.Creator) == 0 {
+			rule.Rule.Creator = rule.Creator
+		}
 		rules = append(rules, rule.Rule)
 	}
 
@@ -91,6 +99,73 @@ func (cli *clientImpl) DestinationRuleDelete(ctx context.Context, ruleID, servic
 	return nil
 }
 
+// Get Rule sync status
+func (cli *clientImpl) DestinationRuleSyncStatus(ctx context.Context, ruleID, serviceName, instance string) (*RuleSyncStatus, error) {
+	if len(serviceName) == 0 {
+		return nil, errors.New("Service Name not found")
+	}
+	if len(instance) == 0 {
+		return nil, errors.New("Service Instance not found")
+	}
+
+	rsp, err := doProxyRequest(ctx, http.MethodGet, serviceName, instance, "/rule", nil, cli)
+	if err != nil {
+		return nil, err
+	}
+	defer rsp.Body.Close()
+
+	ruleData := &ServiceRuleData{}
+	err = json.NewDecoder(rsp.Body).Decode(ruleData)
+	if err != nil {
+		return nil, err
+	}
+
+	return ruleSyncStatus(ruleData, ruleID), nil
+}
+
+// ruleSyncStatus counts the rule as expanded and synced for each app bound to
+// the instance, an app whose expanded rule doesn't exist yet is still pending.
+func ruleSyncStatus(ruleData *ServiceRuleData, ruleID string) *RuleSyncStatus {
+	syncs := map[string]types.RuleSyncInfo{}
+	for _, sync := range ruleData.RulesSync {
+		syncs[sync.RuleID] = sync
+	}
+
+	expanded := map[string]types.Rule{}
+	for _, rule := range ruleData.ExpandedRules {
+		if rule.Metadata["base-ruleid"] == ruleID {
+			expanded[rule.Metadata["app-name"]] = rule
+		}
+	}
+
+	status := &RuleSyncStatus{Total: len(ruleData.ServiceInstance.BindApps)}
+	for _, app := range ruleData.ServiceInstance.BindApps {
+		rule, ok := expanded[app]
+		if !ok {
+			continue
+		}
+
+		sync, ok := syncs[rule.RuleID]
+		if !ok {
+			continue
+		}
+		latest := sync.LatestSync()
+		if latest == nil {
+			continue
+		}
+		if latest.Successful {
+			status.Synced++
+			continue
+		}
+		status.Failed++
+		if len(latest.Error) > 0 {
+			status.Errors = append(status.Errors, fmt.Sprintf("%s: %s", rule.RuleID, latest.Error))
+		}
+	}
+
+	return status
+}
+
 func ParseResourceID(id string) (*ParsedPrimaryID, error) {
 	if len(id) == 0 {
 		return nil, errors.New("ResourceId not found")
@@ -114,6 +189,7 @@ func ParseResourceID(id string) (*ParsedPrimaryID, error) {
 		return nil, errors.New("Destination Rule Key invalid")
 	}
 
+	var err error
 	parsedPrimaryID := &ParsedPrimaryID{}
 	parsedPrimaryID.Service = getID(1, idParts)
 	if len(parsedPrimaryID.Service) == 0 {
@@ -136,15 +212,82 @@ func ParseResourceID(id string) (*ParsedPrimaryID, error) {
 	case DestinationPool:
 		parsedPrimaryID.PoolName = getID(4, idParts)
 	case DestinationCIDR:
-		parsedPrimaryID.CIDR = getID(4, idParts)
+		if parsedPrimaryID.CIDR, parsedPrimaryID.Ports, err = parseIPQualifier(idParts[4:]); err != nil {
+			return nil, err
+		}
 	case DestinationDNS:
 		parsedPrimaryID.DNS = getID(4, idParts)
+		if parsedPrimaryID.Ports, err = parsePortsQualifier(getID(5, idParts)); err != nil {
+			return nil, err
+		}
+	case RuleNameKey:
+		parsedPrimaryID.RuleName = getID(4, idParts)
 	case DestinationRpaaS:
 		parsedPrimaryID.RpaasService = getID(4, idParts)
 		parsedPrimaryID.RpaasInstance = getID(5, idParts)
+	case DestinationKubernetesService:
+		parsedPrimaryID.KubernetesNamespace = getID(4, idParts)
+		parsedPrimaryID.KubernetesService = getID(5, idParts)
+		parsedPrimaryID.KubernetesCluster = getID(6, idParts)
 	default:
 		return nil, errors.New("Parse Resource ID failed")
 	}
 
 	return parsedPrimaryID, nil
 }
+
+// parseIPQualifier parses the IP destination of an import ID and its optional
+// ports. IPv6 addresses contain the ID separator (ex: 2001:db8::/32), so the
+// parts are joined back and the last one is only taken as the ports when the
+// whole value isn't a valid IP or CIDR.
+func parseIPQualifier(parts []string) (string, []types.ProtoPort, error) {
+	ip := strings.Join(parts, "::")
+	if len(parts) < 2 || isIPOrCIDR(ip) {
+		return ip, nil, nil
+	}
+
+	ports, err := parsePortsQualifier(parts[len(parts)-1])
+	if err != nil {
+		return "", nil, err
+	}
+
+	return strings.Join(parts[:len(parts)-1], "::"), ports, nil
+}
+
+func isIPOrCIDR(value string) bool {
+	if net.ParseIP(value) != nil {
+		return true
+	}
+	_, _, err := net.ParseCIDR(value)
+	return err == nil
+}
+
+// parsePortsQualifier parses the optional ports of an import ID, written as
+// <PROTOCOL>:<PORT>[,<PROTOCOL>:<PORT>...] (ex: TCP:80,TCP:443).
+func parsePortsQualifier(value string) ([]types.ProtoPort, error) {
+	if len(value) == 0 {
+		return nil, nil
+	}
+
+	var ports []types.ProtoPort
+	for _, item := range strings.Split(value, ",") {
+		parts := strings.SplitN(strings.TrimSpace(item), ":", 2)
+		if len(parts) != 2 {
+			return nil, errors.Errorf("invalid port %q, the format must be <PROTOCOL>:<PORT>", item)
+		}
+
+		protocol := strings.ToUpper(parts[0])
+		if protocol != "TCP" && protocol != "UDP" {
+			return nil, errors.Errorf("invalid port %q, the protocol must be TCP or UDP", item)
+		}
+
+		number, err := strconv.ParseUint(parts[1], 10, 16)
+		if err != nil || number == 0 {
+			return nil, errors.Errorf("invalid port %q, the port must be a number between 1 and 65535", item)
+		}
+
+		ports = append(ports, types.ProtoPort{Protocol: protocol, Port: uint16(number)})
+	}
+
+	return ports, nil
+}
@@ -0,0 +1,180 @@
+// Copyright 2021 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package acl
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tsuru/acl-api/api/types"
+)
+
+func TestParseResourceID(t *testing.T) {
+	tests := []struct {
+		id       string
+		expected *ParsedPrimaryID
+		err      string
+	}{
+		{
+			id:       "acl::my-acl::my-rule",
+			expected: &ParsedPrimaryID{Service: "acl", Instance: "my-acl", RuleID: "my-rule"},
+		},
+		{
+			id:       "acl-rule::acl::my-acl::app::my-app",
+			expected: &ParsedPrimaryID{Service: "acl", Instance: "my-acl", Type: DestinationApp, AppName: "my-app"},
+		},
+		{
+			id:       "acl-rule::acl::my-acl::rpaas::rpaasv2-be::my-rpaas",
+			expected: &ParsedPrimaryID{Service: "acl", Instance: "my-acl", Type: DestinationRpaaS, RpaasService: "rpaasv2-be", RpaasInstance: "my-rpaas"},
+		},
+		{
+			id: "acl-rule::acl::my-acl::kubernetes_service::my-namespace::my-service::my-cluster",
+			expected: &ParsedPrimaryID{
+				Service:             "acl",
+				Instance:            "my-acl",
+				Type:                DestinationKubernetesService,
+				KubernetesNamespace: "my-namespace",
+				KubernetesService:   "my-service",
+				KubernetesCluster:   "my-cluster",
+			},
+		},
+		{
+			id:       "acl-rule::acl::my-acl::dns::example.org",
+			expected: &ParsedPrimaryID{Service: "acl", Instance: "my-acl", Type: DestinationDNS, DNS: "example.org"},
+		},
+		{
+			id: "acl-rule::acl::my-acl::dns::example.org::tcp:80,TCP:443",
+			expected: &ParsedPrimaryID{
+				Service:  "acl",
+				Instance: "my-acl",
+				Type:     DestinationDNS,
+				DNS:      "example.org",
+				Ports:    []types.ProtoPort{{Protocol: "TCP", Port: 80}, {Protocol: "TCP", Port: 443}},
+			},
+		},
+		{
+			id: "acl-rule::acl::my-acl::ip::10.0.0.0/8::UDP:53",
+			expected: &ParsedPrimaryID{
+				Service:  "acl",
+				Instance: "my-acl",
+				Type:     DestinationCIDR,
+				CIDR:     "10.0.0.0/8",
+				Ports:    []types.ProtoPort{{Protocol: "UDP", Port: 53}},
+			},
+		},
+		{
+			id:       "acl-rule::acl::my-acl::ip::2001:db8::/32",
+			expected: &ParsedPrimaryID{Service: "acl", Instance: "my-acl", Type: DestinationCIDR, CIDR: "2001:db8::/32"},
+		},
+		{
+			id:       "acl-rule::acl::my-acl::ip::::1",
+			expected: &ParsedPrimaryID{Service: "acl", Instance: "my-acl", Type: DestinationCIDR, CIDR: "::1"},
+		},
+		{
+			id: "acl-rule::acl::my-acl::ip::2001:db8::/32::TCP:443",
+			expected: &ParsedPrimaryID{
+				Service:  "acl",
+				Instance: "my-acl",
+				Type:     DestinationCIDR,
+				CIDR:     "2001:db8::/32",
+				Ports:    []types.ProtoPort{{Protocol: "TCP", Port: 443}},
+			},
+		},
+		{
+			id:       "acl-rule::acl::my-acl::name::example-org",
+			expected: &ParsedPrimaryID{Service: "acl", Instance: "my-acl", Type: RuleNameKey, RuleName: "example-org"},
+		},
+		{
+			id:  "acl-rule::acl::my-acl::dns::example.org::443",
+			err: `invalid port "443", the format must be <PROTOCOL>:<PORT>`,
+		},
+		{
+			id:  "acl-rule::acl::my-acl::dns::example.org::SCTP:443",
+			err: `invalid port "SCTP:443", the protocol must be TCP or UDP`,
+		},
+		{
+			id:  "acl-rule::acl::my-acl::ip::10.0.0.0/8::TCP:70000",
+			err: `invalid port "TCP:70000", the port must be a number between 1 and 65535`,
+		},
+		{
+			id:  "acl::my-acl",
+			err: "Parse Resource ID invalid, the format must be <SERVICE>::<INSTANCE>::<RULE_ID>",
+		},
+		{
+			id:  "acl-rule::acl::my-acl::unknown::value",
+			err: "Parse Resource ID failed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			parsed, err := ParseResourceID(tt.id)
+			if tt.err != "" {
+				require.EqualError(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, parsed)
+		})
+	}
+}
+
+func TestRuleSyncStatus(t *testing.T) {
+	expanded := func(ruleID, baseRuleID, app string) types.Rule {
+		return types.Rule{RuleID: ruleID, Metadata: map[string]string{"base-ruleid": baseRuleID, "app-name": app}}
+	}
+	ruleData := &ServiceRuleData{
+		ServiceInstance: types.ServiceInstance{
+			BindApps: []string{"app1", "app2", "app3"},
+		},
+		ExpandedRules: []types.Rule{
+			expanded("my-rule-app1", "my-rule", "app1"),
+			expanded("my-rule-app2", "my-rule", "app2"),
+			expanded("my-rule-app3", "my-rule", "app3"),
+			expanded("my-rule-unbound", "my-rule", "unbound-app"),
+			expanded("other-rule-app1", "other-rule", "app1"),
+		},
+		RulesSync: []types.RuleSyncInfo{
+			{RuleID: "my-rule-app1", Syncs: []types.RuleSyncData{{Successful: false, Error: "old error"}, {Successful: true}}},
+			{RuleID: "my-rule-app2", Syncs: []types.RuleSyncData{{Successful: false, Error: "connection refused"}}},
+			{RuleID: "my-rule-unbound", Syncs: []types.RuleSyncData{{Successful: true}}},
+			{RuleID: "other-rule-app1", Syncs: []types.RuleSyncData{{Successful: true}}},
+		},
+	}
+
+	status := ruleSyncStatus(ruleData, "my-rule")
+	require.Equal(t, &RuleSyncStatus{
+		Total:  3,
+		Synced: 1,
+		Failed: 1,
+		Errors: []string{"my-rule-app2: connection refused"},
+	}, status)
+	require.False(t, status.Done())
+	require.False(t, status.HasFailed())
+
+	ruleData.RulesSync = append(ruleData.RulesSync, types.RuleSyncInfo{RuleID: "my-rule-app3", Syncs: []types.RuleSyncData{{Successful: false, Error: "timeout"}}})
+	status = ruleSyncStatus(ruleData, "my-rule")
+	require.False(t, status.Done())
+	require.True(t, status.HasFailed())
+	require.Equal(t, []string{"my-rule-app2: connection refused", "my-rule-app3: timeout"}, status.Errors)
+
+	// expanded for app1 only, app2 and app3 are still pending
+	status = ruleSyncStatus(ruleData, "other-rule")
+	require.Equal(t, &RuleSyncStatus{Total: 3, Synced: 1}, status)
+	require.False(t, status.Done())
+	require.False(t, status.HasFailed())
+
+	status = ruleSyncStatus(ruleData, "new-rule")
+	require.Equal(t, 3, status.Total)
+	require.False(t, status.Done())
+	require.False(t, status.HasFailed())
+}
+
+func TestRuleSyncStatusWithoutBoundApps(t *testing.T) {
+	status := ruleSyncStatus(&ServiceRuleData{}, "my-rule")
+	require.Equal(t, &RuleSyncStatus{}, status)
+	require.True(t, status.Done())
+	require.False(t, status.HasFailed())
+}
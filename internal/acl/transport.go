@@ -0,0 +1,109 @@
+package acl
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ClientOptions holds the settings used to build the HTTP client that talks
+// to the tsuru API.
+type ClientOptions struct {
+	// SkipCertVerification disables TLS certificate verification.
+	SkipCertVerification bool
+
+	// CACertFile and CACert are a path to, or the contents of, a PEM bundle
+	// of certificate authorities trusted in addition to the system pool.
+	CACertFile string
+	CACert     string
+
+	// ClientCertFile/ClientKeyFile and ClientCert/ClientKey are a path to, or
+	// the contents of, a PEM certificate and key used for mutual TLS.
+	ClientCertFile string
+	ClientKeyFile  string
+	ClientCert     string
+	ClientKey      string
+
+	// Timeout limits the duration of each request, zero means no timeout.
+	Timeout time.Duration
+
+	// Retry configures retries of requests failing with transient errors.
+	Retry RetryPolicy
+}
+
+func newHTTPClient(opts ClientOptions) (*http.Client, error) {
+	tlsConfig, err := newTLSConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+
+	return &http.Client{
+		Transport: transport,
+		Timeout:   opts.Timeout,
+	}, nil
+}
+
+func newTLSConfig(opts ClientOptions) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: opts.SkipCertVerification,
+	}
+
+	caCert := []byte(opts.CACert)
+	if len(opts.CACertFile) > 0 {
+		data, err := os.ReadFile(opts.CACertFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not read CA certificate file")
+		}
+		caCert = append(caCert, '\n')
+		caCert = append(caCert, data...)
+	}
+
+	if len(opts.CACert) > 0 || len(opts.CACertFile) > 0 {
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(caCert) {
+			return nil, errors.New("no valid certificate found in CA certificate")
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	clientCert := []byte(opts.ClientCert)
+	if len(opts.ClientCertFile) > 0 {
+		data, err := os.ReadFile(opts.ClientCertFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not read client certificate file")
+		}
+		clientCert = data
+	}
+
+	clientKey := []byte(opts.ClientKey)
+	if len(opts.ClientKeyFile) > 0 {
+		data, err := os.ReadFile(opts.ClientKeyFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not read client key file")
+		}
+		clientKey = data
+	}
+
+	if len(clientCert) > 0 || len(clientKey) > 0 {
+		if len(clientCert) == 0 || len(clientKey) == 0 {
+			return nil, errors.New("client certificate and client key must be set together")
+		}
+		cert, err := tls.X509KeyPair(clientCert, clientKey)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not load client certificate")
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
@@ -0,0 +1,76 @@
+// Copyright 2021 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package acl
+
+import (
+	"context"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewHTTPClientTLS(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	caCert := string(pem.EncodeToMemory(&pem.Block{
+		Type:  "CERTIFICATE",
+		Bytes: server.Certificate().Raw,
+	}))
+
+	tests := []struct {
+		name    string
+		opts    ClientOptions
+		wantErr bool
+	}{
+		{
+			name:    "default verification",
+			opts:    ClientOptions{},
+			wantErr: true,
+		},
+		{
+			name: "skip cert verification",
+			opts: ClientOptions{SkipCertVerification: true},
+		},
+		{
+			name: "custom ca cert",
+			opts: ClientOptions{CACert: caCert},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			httpClient, err := newHTTPClient(tt.opts)
+			require.NoError(t, err)
+
+			req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, server.URL, nil)
+			require.NoError(t, err)
+
+			rsp, err := httpClient.Do(req)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			rsp.Body.Close()
+		})
+	}
+}
+
+func TestNewTLSConfigInvalid(t *testing.T) {
+	_, err := newTLSConfig(ClientOptions{CACert: "not a certificate"})
+	require.Error(t, err)
+
+	_, err = newTLSConfig(ClientOptions{ClientCert: "cert"})
+	require.EqualError(t, err, "client certificate and client key must be set together")
+
+	_, err = newTLSConfig(ClientOptions{CACertFile: "/non/existent/ca.pem"})
+	require.Error(t, err)
+}
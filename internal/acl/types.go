@@ -11,7 +11,13 @@ const (
 	DestinationCIDR  = "ip"
 	DestinationDNS   = "dns"
 
+	DestinationKubernetesService = "kubernetes_service"
+
 	DestinationRulekey = "acl-rule"
+
+	// RuleNameKey is used in place of a destination type to import a rule by
+	// its name.
+	RuleNameKey = "name"
 )
 
 var Destinations = []string{
@@ -20,17 +26,44 @@ var Destinations = []string{
 	DestinationRpaaS,
 	DestinationCIDR,
 	DestinationDNS,
+	DestinationKubernetesService,
 }
 
 type ServiceRuleData struct {
 	ServiceInstance types.ServiceInstance
+	ExpandedRules   []types.Rule
+	RulesSync       []types.RuleSyncInfo
+}
+
+// RuleSyncStatus summarizes how far acl-api sync workers are on applying a
+// destination rule, which is expanded in one rule per app bound to the
+// instance.
+type RuleSyncStatus struct {
+	// Total is the number of apps bound to the instance.
+	Total  int
+	Synced int
+	Failed int
+	Errors []string
+}
+
+// Done reports whether the rule was applied for every bound app, a rule of an
+// instance without bound apps has nothing to apply and is done.
+func (s *RuleSyncStatus) Done() bool {
+	return s.Synced == s.Total
+}
+
+// HasFailed reports whether the latest sync failed for every bound app not
+// synchronized yet, so waiting longer won't make it done.
+func (s *RuleSyncStatus) HasFailed() bool {
+	return s.Failed > 0 && s.Synced+s.Failed == s.Total
 }
 
 type ParsedPrimaryID struct {
 	Service  string
 	Instance string
 
-	RuleID string
+	RuleID   string
+	RuleName string
 
 	Type string
 
@@ -39,6 +72,14 @@ type ParsedPrimaryID struct {
 	CIDR     string
 	DNS      string
 
+	// Ports optionally narrows ip and dns matches to rules with exactly
+	// these ports.
+	Ports []types.ProtoPort
+
 	RpaasService  string
 	RpaasInstance string
+
+	KubernetesNamespace string
+	KubernetesService   string
+	KubernetesCluster   string
 }
@@ -1,8 +1,12 @@
 package acl
 
 import (
+	"fmt"
+	"net"
+	"sort"
 	"strings"
 
+	"github.com/pkg/errors"
 	"github.com/tsuru/acl-api/api/types"
 )
 
@@ -34,60 +38,250 @@ func getID(key int, ids []string) string {
 	return ids[key]
 }
 
-func FindRuleByParsedPrimaryID(rules []types.Rule, id *ParsedPrimaryID) (rule *types.Rule) {
+func FindRulesByParsedPrimaryID(rules []types.Rule, id *ParsedPrimaryID) (found []types.Rule) {
 	for _, v := range rules {
-		// Rule ID
-		if len(id.RuleID) > 0 && v.RuleID == id.RuleID {
-			return &v
+		if !v.Removed && matchParsedPrimaryID(v, id) {
+			found = append(found, v)
 		}
+	}
 
-		// App Name
-		if v.Destination.TsuruApp != nil && len(id.AppName) > 0 {
-			if v.Destination.TsuruApp.AppName == id.AppName {
-				return &v
-			}
+	return found
+}
+
+// FindSingleRuleByParsedPrimaryID returns the only active rule matching id,
+// failing with the candidate rule IDs when more than one rule matches.
+func FindSingleRuleByParsedPrimaryID(rules []types.Rule, id *ParsedPrimaryID) (*types.Rule, error) {
+	found := FindRulesByParsedPrimaryID(rules, id)
+	switch len(found) {
+	case 0:
+		return nil, errors.New("rule not found")
+	case 1:
+		return &found[0], nil
+	}
+
+	ruleIDs := make([]string, 0, len(found))
+	for _, rule := range found {
+		ruleIDs = append(ruleIDs, rule.RuleID)
+	}
+
+	hint := "use <SERVICE>::<INSTANCE>::<RULE_ID>"
+	if id.Type == DestinationCIDR || id.Type == DestinationDNS {
+		hint += " or add the rule ports to the ID (ex: ::TCP:443)"
+	}
+
+	return nil, errors.Errorf("%d rules found (%s), %s", len(found), strings.Join(ruleIDs, ", "), hint)
+}
+
+func matchParsedPrimaryID(v types.Rule, id *ParsedPrimaryID) bool {
+	// Rule ID
+	if len(id.RuleID) > 0 && v.RuleID == id.RuleID {
+		return true
+	}
+
+	// Rule Name
+	if len(id.RuleName) > 0 && v.RuleName == id.RuleName {
+		return true
+	}
+
+	// App Name
+	if RuleDestinationType(&v) == DestinationApp && len(id.AppName) > 0 {
+		if v.Destination.TsuruApp.AppName == id.AppName {
+			return true
 		}
+	}
 
-		// Pool Name
-		if v.Destination.TsuruApp != nil && len(id.PoolName) > 0 {
-			if v.Destination.TsuruApp.PoolName == id.PoolName {
-				return &v
-			}
+	// Pool Name, app rules may also carry the app pool so they never match
+	if RuleDestinationType(&v) == DestinationPool && len(id.PoolName) > 0 {
+		if v.Destination.TsuruApp.PoolName == id.PoolName {
+			return true
 		}
+	}
 
-		// Rpaas Instance
-		if v.Destination.RpaasInstance != nil && len(id.RpaasService) > 0 && len(id.RpaasInstance) > 0 {
-			if v.Destination.RpaasInstance.ServiceName == id.RpaasService {
-				if v.Destination.RpaasInstance.Instance == id.RpaasInstance {
-					return &v
-				}
+	// Rpaas Instance
+	if v.Destination.RpaasInstance != nil && len(id.RpaasService) > 0 && len(id.RpaasInstance) > 0 {
+		if v.Destination.RpaasInstance.ServiceName == id.RpaasService {
+			if v.Destination.RpaasInstance.Instance == id.RpaasInstance {
+				return true
 			}
 		}
+	}
 
-		// CIDR / IP
-		if v.Destination.ExternalIP != nil && len(id.CIDR) > 0 {
-			if v.Destination.ExternalIP.IP == id.CIDR {
-				return &v
+	// Kubernetes Service
+	if v.Destination.KubernetesService != nil && len(id.KubernetesNamespace) > 0 && len(id.KubernetesService) > 0 {
+		if KubernetesNamespace(v.Destination.KubernetesService.Namespace) == KubernetesNamespace(id.KubernetesNamespace) &&
+			v.Destination.KubernetesService.ServiceName == id.KubernetesService {
+			if len(id.KubernetesCluster) == 0 || v.Destination.KubernetesService.ClusterName == id.KubernetesCluster {
+				return true
 			}
 		}
+	}
 
-		// DNS
-		if v.Destination.ExternalDNS != nil && len(id.DNS) > 0 {
-			if v.Destination.ExternalDNS.Name == id.DNS {
-				return &v
-			}
+	// CIDR / IP
+	if v.Destination.ExternalIP != nil && len(id.CIDR) > 0 {
+		if NormalizeCIDR(v.Destination.ExternalIP.IP) == NormalizeCIDR(id.CIDR) &&
+			(id.Ports == nil || PortsEqual(v.Destination.ExternalIP.Ports, id.Ports)) {
+			return true
 		}
 	}
 
-	return nil
+	// DNS
+	if v.Destination.ExternalDNS != nil && len(id.DNS) > 0 {
+		if NormalizeDNS(v.Destination.ExternalDNS.Name) == NormalizeDNS(id.DNS) &&
+			(id.Ports == nil || PortsEqual(v.Destination.ExternalDNS.Ports, id.Ports)) {
+			return true
+		}
+	}
+
+	return false
 }
 
 func FindRuleBySingleID(rules []types.Rule, id string) (rule *types.Rule) {
 	for _, v := range rules {
-		if v.RuleID == id {
+		if !v.Removed && v.RuleID == id {
 			return &v
 		}
 	}
 
 	return nil
 }
+
+// ActiveRules filters out rules flagged as removed by acl-api.
+func ActiveRules(rules []types.Rule) []types.Rule {
+	var active []types.Rule
+	for _, rule := range rules {
+		if !rule.Removed {
+			active = append(active, rule)
+		}
+	}
+	return active
+}
+
+func RuleDestinationType(rule *types.Rule) string {
+	switch {
+	case rule.Destination.TsuruApp != nil && len(rule.Destination.TsuruApp.AppName) > 0:
+		return DestinationApp
+	case rule.Destination.TsuruApp != nil:
+		return DestinationPool
+	case rule.Destination.RpaasInstance != nil:
+		return DestinationRpaaS
+	case rule.Destination.ExternalIP != nil:
+		return DestinationCIDR
+	case rule.Destination.ExternalDNS != nil:
+		return DestinationDNS
+	case rule.Destination.KubernetesService != nil:
+		return DestinationKubernetesService
+	}
+
+	return ""
+}
+
+// DestinationKey returns a canonical representation of the rule destination,
+// two rules with the same key are considered the same rule by acl-api.
+func DestinationKey(rule *types.Rule) string {
+	destination := rule.Destination
+	destinationType := RuleDestinationType(rule)
+
+	switch destinationType {
+	case DestinationApp:
+		return GenerateID([]string{destinationType, destination.TsuruApp.AppName})
+	case DestinationPool:
+		return GenerateID([]string{destinationType, destination.TsuruApp.PoolName})
+	case DestinationRpaaS:
+		return GenerateID([]string{destinationType, destination.RpaasInstance.ServiceName, destination.RpaasInstance.Instance})
+	case DestinationKubernetesService:
+		return GenerateID([]string{
+			destinationType,
+			KubernetesNamespace(destination.KubernetesService.Namespace),
+			destination.KubernetesService.ServiceName,
+			destination.KubernetesService.ClusterName,
+		})
+	case DestinationCIDR:
+		return GenerateID([]string{destinationType, NormalizeCIDR(destination.ExternalIP.IP), portsKey(destination.ExternalIP.Ports)})
+	case DestinationDNS:
+		return GenerateID([]string{destinationType, NormalizeDNS(destination.ExternalDNS.Name), portsKey(destination.ExternalDNS.Ports)})
+	}
+
+	return ""
+}
+
+// RulesConflict reports whether acl-api refuses to add one of the rules while
+// the other exists: besides rules with the same key, an ip or dns rule without
+// ports is taken as the same rule as any other rule for its host.
+func RulesConflict(a, b *types.Rule) bool {
+	if DestinationKey(a) == DestinationKey(b) {
+		return true
+	}
+
+	destinationA, destinationB := a.Destination, b.Destination
+	switch {
+	case destinationA.ExternalIP != nil && destinationB.ExternalIP != nil:
+		return NormalizeCIDR(destinationA.ExternalIP.IP) == NormalizeCIDR(destinationB.ExternalIP.IP) &&
+			(len(destinationA.ExternalIP.Ports) == 0 || len(destinationB.ExternalIP.Ports) == 0)
+	case destinationA.ExternalDNS != nil && destinationB.ExternalDNS != nil:
+		return NormalizeDNS(destinationA.ExternalDNS.Name) == NormalizeDNS(destinationB.ExternalDNS.Name) &&
+			(len(destinationA.ExternalDNS.Ports) == 0 || len(destinationB.ExternalDNS.Ports) == 0)
+	}
+
+	return false
+}
+
+// PortsEqual reports whether both lists hold the same ports, ignoring order,
+// duplicates and protocol case.
+func PortsEqual(a, b []types.ProtoPort) bool {
+	return portsKey(a) == portsKey(b)
+}
+
+func portsKey(ports []types.ProtoPort) string {
+	seen := map[string]struct{}{}
+	keys := make([]string, 0, len(ports))
+	for _, port := range ports {
+		key := fmt.Sprintf("%s:%d", strings.ToUpper(port.Protocol), port.Port)
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return strings.Join(keys, ",")
+}
+
+// NormalizeCIDR returns the canonical form of an IP destination, which is its
+// network address and prefix length. A bare IP is taken as a /32 (or /128 for
+// IPv6) and values that are not an IP nor a CIDR are returned unchanged.
+func NormalizeCIDR(value string) string {
+	if ip := net.ParseIP(value); ip != nil {
+		if ip4 := ip.To4(); ip4 != nil {
+			return (&net.IPNet{IP: ip4, Mask: net.CIDRMask(32, 32)}).String()
+		}
+		return (&net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}).String()
+	}
+
+	_, network, err := net.ParseCIDR(value)
+	if err != nil {
+		return value
+	}
+
+	return network.String()
+}
+
+// NormalizeDNS returns a DNS destination as stored by acl-api: lower case,
+// without the trailing dot and with wildcards ("*.example.org") written as
+// ".example.org".
+func NormalizeDNS(name string) string {
+	name = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(name)), ".")
+	if strings.HasPrefix(name, "*.") {
+		name = name[1:]
+	}
+	return name
+}
+
+// KubernetesNamespace returns the namespace acl-api assumes for a kubernetes
+// service rule, which is "default" when none is set.
+func KubernetesNamespace(namespace string) string {
+	if len(namespace) == 0 {
+		return "default"
+	}
+
+	return namespace
+}
@@ -0,0 +1,203 @@
+// Copyright 2021 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package acl
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tsuru/acl-api/api/types"
+)
+
+func TestFindRuleIgnoresRemovedRules(t *testing.T) {
+	rules := []types.Rule{
+		{
+			RuleID:  "removed-rule",
+			Removed: true,
+			Destination: types.RuleType{
+				TsuruApp: &types.TsuruAppRule{AppName: "my-app"},
+			},
+		},
+		{
+			RuleID: "active-rule",
+			Destination: types.RuleType{
+				TsuruApp: &types.TsuruAppRule{AppName: "my-app"},
+			},
+		},
+	}
+
+	require.Nil(t, FindRuleBySingleID(rules, "removed-rule"))
+	require.Equal(t, "active-rule", FindRuleBySingleID(rules, "active-rule").RuleID)
+
+	rule, err := FindSingleRuleByParsedPrimaryID(rules, &ParsedPrimaryID{AppName: "my-app"})
+	require.NoError(t, err)
+	require.Equal(t, "active-rule", rule.RuleID)
+	require.Len(t, FindRulesByParsedPrimaryID(rules, &ParsedPrimaryID{AppName: "my-app"}), 1)
+
+	require.Equal(t, []types.Rule{rules[1]}, ActiveRules(rules))
+}
+
+func TestPortsEqual(t *testing.T) {
+	ports := []types.ProtoPort{
+		{Protocol: "TCP", Port: 443},
+		{Protocol: "tcp", Port: 80},
+	}
+
+	require.True(t, PortsEqual(ports, []types.ProtoPort{
+		{Protocol: "TCP", Port: 80},
+		{Protocol: "TCP", Port: 443},
+		{Protocol: "tcp", Port: 443},
+	}))
+	require.False(t, PortsEqual(ports, []types.ProtoPort{
+		{Protocol: "UDP", Port: 80},
+		{Protocol: "TCP", Port: 443},
+	}))
+	require.False(t, PortsEqual(ports, nil))
+}
+
+func TestRulesConflict(t *testing.T) {
+	dns := func(name string, ports ...types.ProtoPort) *types.Rule {
+		return &types.Rule{Destination: types.RuleType{ExternalDNS: &types.ExternalDNSRule{Name: name, Ports: ports}}}
+	}
+	ip := func(cidr string, ports ...types.ProtoPort) *types.Rule {
+		return &types.Rule{Destination: types.RuleType{ExternalIP: &types.ExternalIPRule{IP: cidr, Ports: ports}}}
+	}
+	https := types.ProtoPort{Protocol: "TCP", Port: 443}
+	http := types.ProtoPort{Protocol: "TCP", Port: 80}
+
+	require.True(t, RulesConflict(dns("example.org"), dns("Example.org.", https)))
+	require.True(t, RulesConflict(dns("example.org", https), dns("example.org")))
+	require.True(t, RulesConflict(dns("example.org", https), dns("example.org", https)))
+	require.False(t, RulesConflict(dns("example.org", https), dns("example.org", http)))
+	require.False(t, RulesConflict(dns("example.org"), dns("other.example.org", https)))
+	require.True(t, RulesConflict(ip("10.0.0.1"), ip("10.0.0.1/32", https)))
+	require.False(t, RulesConflict(ip("10.0.0.0/8"), dns("example.org")))
+	require.False(t, RulesConflict(
+		&types.Rule{Destination: types.RuleType{TsuruApp: &types.TsuruAppRule{AppName: "my-app"}}},
+		&types.Rule{Destination: types.RuleType{TsuruApp: &types.TsuruAppRule{PoolName: "my-app"}}},
+	))
+}
+
+func TestNormalizeCIDR(t *testing.T) {
+	tests := map[string]string{
+		"10.0.0.1":             "10.0.0.1/32",
+		"10.0.0.1/24":          "10.0.0.0/24",
+		"10.0.0.0/24":          "10.0.0.0/24",
+		"::ffff:10.0.0.1":      "10.0.0.1/32",
+		"2001:0db8:0000::0001": "2001:db8::1/128",
+		"2001:0db8:0000::1/64": "2001:db8::/64",
+		"not-an-ip":            "not-an-ip",
+		"10.0.0.0/33":          "10.0.0.0/33",
+	}
+
+	for value, expected := range tests {
+		require.Equal(t, expected, NormalizeCIDR(value), value)
+	}
+}
+
+func TestNormalizeDNS(t *testing.T) {
+	tests := map[string]string{
+		"example.org":    "example.org",
+		"Example.ORG.":   "example.org",
+		"*.example.org":  ".example.org",
+		".example.org":   ".example.org",
+		"*.Example.org.": ".example.org",
+	}
+
+	for name, expected := range tests {
+		require.Equal(t, expected, NormalizeDNS(name), name)
+	}
+}
+
+func TestFindRuleAppAndPoolNeverCrossMatch(t *testing.T) {
+	rules := []types.Rule{
+		{
+			RuleID: "app-rule",
+			Destination: types.RuleType{
+				TsuruApp: &types.TsuruAppRule{AppName: "my-app", PoolName: "my-pool"},
+			},
+		},
+		{
+			RuleID: "pool-rule",
+			Destination: types.RuleType{
+				TsuruApp: &types.TsuruAppRule{PoolName: "my-pool"},
+			},
+		},
+	}
+
+	rule, err := FindSingleRuleByParsedPrimaryID(rules, &ParsedPrimaryID{PoolName: "my-pool"})
+	require.NoError(t, err)
+	require.Equal(t, "pool-rule", rule.RuleID)
+	require.Len(t, FindRulesByParsedPrimaryID(rules, &ParsedPrimaryID{PoolName: "my-pool"}), 1)
+
+	rule, err = FindSingleRuleByParsedPrimaryID(rules, &ParsedPrimaryID{AppName: "my-app"})
+	require.NoError(t, err)
+	require.Equal(t, "app-rule", rule.RuleID)
+
+	_, err = FindSingleRuleByParsedPrimaryID(rules, &ParsedPrimaryID{AppName: "my-pool"})
+	require.EqualError(t, err, "rule not found")
+	_, err = FindSingleRuleByParsedPrimaryID(rules[:1], &ParsedPrimaryID{PoolName: "my-pool"})
+	require.EqualError(t, err, "rule not found")
+}
+
+func TestFindSingleRuleByParsedPrimaryID(t *testing.T) {
+	rules := []types.Rule{
+		{
+			RuleID:   "dns-80",
+			RuleName: "example-org-http",
+			Destination: types.RuleType{
+				ExternalDNS: &types.ExternalDNSRule{Name: "example.org", Ports: []types.ProtoPort{{Protocol: "TCP", Port: 80}}},
+			},
+		},
+		{
+			RuleID:   "dns-443",
+			RuleName: "example-org-https",
+			Destination: types.RuleType{
+				ExternalDNS: &types.ExternalDNSRule{Name: "example.org", Ports: []types.ProtoPort{{Protocol: "tcp", Port: 443}}},
+			},
+		},
+		{
+			RuleID: "ipv6",
+			Destination: types.RuleType{
+				ExternalIP: &types.ExternalIPRule{IP: "2001:db8::/32"},
+			},
+		},
+		{
+			RuleID:   "app",
+			RuleName: "my-app",
+			Destination: types.RuleType{
+				TsuruApp: &types.TsuruAppRule{AppName: "my-app"},
+			},
+		},
+	}
+
+	_, err := FindSingleRuleByParsedPrimaryID(rules, &ParsedPrimaryID{Type: DestinationDNS, DNS: "example.org"})
+	require.EqualError(t, err, "2 rules found (dns-80, dns-443), use <SERVICE>::<INSTANCE>::<RULE_ID> or add the rule ports to the ID (ex: ::TCP:443)")
+
+	rule, err := FindSingleRuleByParsedPrimaryID(rules, &ParsedPrimaryID{
+		Type:  DestinationDNS,
+		DNS:   "example.org",
+		Ports: []types.ProtoPort{{Protocol: "TCP", Port: 443}},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "dns-443", rule.RuleID)
+
+	rule, err = FindSingleRuleByParsedPrimaryID(rules, &ParsedPrimaryID{Type: RuleNameKey, RuleName: "example-org-http"})
+	require.NoError(t, err)
+	require.Equal(t, "dns-80", rule.RuleID)
+
+	_, err = FindSingleRuleByParsedPrimaryID(rules, &ParsedPrimaryID{
+		Type:  DestinationDNS,
+		DNS:   "example.org",
+		Ports: []types.ProtoPort{{Protocol: "UDP", Port: 443}},
+	})
+	require.EqualError(t, err, "rule not found")
+
+	parsed, err := ParseResourceID("acl-rule::acl::my-acl::ip::2001:DB8::/32")
+	require.NoError(t, err)
+	rule, err = FindSingleRuleByParsedPrimaryID(rules, parsed)
+	require.NoError(t, err)
+	require.Equal(t, "ipv6", rule.RuleID)
+}
@@ -0,0 +1,104 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/tsuru/terraform-provider-acl/internal/acl"
+)
+
+func dataSourceACLDestinationRule() *schema.Resource {
+	oneDestination := acl.Destinations
+
+	ruleSchema := destinationRuleDataSchema()
+	for _, key := range oneDestination {
+		ruleSchema[key].Computed = false
+		ruleSchema[key].Optional = true
+		ruleSchema[key].ExactlyOneOf = oneDestination
+	}
+	ruleSchema["rpaas"].MaxItems = 1
+	ruleSchema["rpaas"].Elem = rpaasSchema("rpaas")
+	ruleSchema["kubernetes_service"].MaxItems = 1
+	ruleSchema["kubernetes_service"].Elem = kubernetesServiceSchema()
+
+	ruleSchema["instance"] = &schema.Schema{
+		Type:        schema.TypeString,
+		Required:    true,
+		Description: "ACL Instance Name",
+	}
+	ruleSchema["service_name"] = &schema.Schema{
+		Type:        schema.TypeString,
+		Optional:    true,
+		Default:     "acl",
+		Description: "ACL Service Name",
+	}
+
+	return &schema.Resource{
+		ReadContext: dataSourceACLDestinationRuleRead,
+		Schema:      ruleSchema,
+	}
+}
+
+func dataSourceACLDestinationRuleRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	cli := m.(*aclProvider).client
+
+	serviceName := d.Get("service_name").(string)
+	instance := d.Get("instance").(string)
+
+	primaryID := &acl.ParsedPrimaryID{
+		Service:  serviceName,
+		Instance: instance,
+		AppName:  d.Get("app").(string),
+		PoolName: d.Get("pool").(string),
+		CIDR:     d.Get("ip").(string),
+		DNS:      d.Get("dns").(string),
+	}
+	if rpaas := parseRpaas(d); rpaas != nil {
+		primaryID.RpaasService = rpaas.ServiceName
+		primaryID.RpaasInstance = rpaas.Instance
+	}
+	if service := parseKubernetesService(d); service != nil {
+		primaryID.KubernetesNamespace = service.Namespace
+		primaryID.KubernetesService = service.ServiceName
+		primaryID.KubernetesCluster = service.ClusterName
+	}
+
+	rules, err := cli.DestinationRules(ctx, serviceName, instance)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	found := acl.FindRulesByParsedPrimaryID(rules, primaryID)
+	if len(found) == 0 {
+		return diag.Diagnostics{{
+			Severity: diag.Error,
+			Summary:  "Destination rule not found",
+			Detail:   fmt.Sprintf("no rule in instance %q of service %q matches the given destination", instance, serviceName),
+		}}
+	}
+
+	if len(found) > 1 {
+		var ruleIDs []string
+		for _, rule := range found {
+			ruleIDs = append(ruleIDs, rule.RuleID)
+		}
+		return diag.Diagnostics{{
+			Severity: diag.Error,
+			Summary:  "Multiple destination rules found",
+			Detail:   fmt.Sprintf("rules %s in instance %q of service %q match the given destination", strings.Join(ruleIDs, ", "), instance, serviceName),
+		}}
+	}
+
+	rule := &found[0]
+	for key, value := range flattenRule(rule) {
+		if err := d.Set(key, value); err != nil {
+			return diag.FromErr(err)
+		}
+	}
+
+	d.SetId(ruleResourceID(serviceName, instance, rule.RuleID))
+	return nil
+}
@@ -0,0 +1,127 @@
+// Copyright 2021 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package provider
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	echo "github.com/labstack/echo/v4"
+	"github.com/tsuru/acl-api/api/types"
+	"github.com/tsuru/terraform-provider-acl/internal/acl"
+)
+
+func TestAccDataSourceDestinationRule(t *testing.T) {
+	fakeServer := echo.New()
+	rules := []types.ServiceRule{
+		{
+			Rule: types.Rule{
+				RuleID: "my-app-rule",
+				Destination: types.RuleType{
+					TsuruApp: &types.TsuruAppRule{
+						AppName: "my-destination-app",
+					},
+				},
+			},
+		},
+		{
+			Rule: types.Rule{
+				RuleID: "my-dns-rule-80",
+				Destination: types.RuleType{
+					ExternalDNS: &types.ExternalDNSRule{
+						Name: "example.org",
+						Ports: []types.ProtoPort{
+							{
+								Protocol: "TCP",
+								Port:     80,
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			Rule: types.Rule{
+				RuleID: "my-dns-rule-443",
+				Destination: types.RuleType{
+					ExternalDNS: &types.ExternalDNSRule{
+						Name: "example.org",
+						Ports: []types.ProtoPort{
+							{
+								Protocol: "TCP",
+								Port:     443,
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	fakeServer.Any("/services/acl/proxy/:instance", func(c echo.Context) error {
+		callback := c.QueryParam("callback")
+		if callback == "/rule" && c.Request().Method == http.MethodGet {
+			return c.JSON(http.StatusOK, &acl.ServiceRuleData{
+				ServiceInstance: types.ServiceInstance{
+					BaseRules: rules,
+				},
+			})
+		}
+		t.Fatalf("method=%q, path=%q, callback=%q, err=\"Not found\"",
+			c.Request().Method,
+			c.Path(),
+			callback,
+		)
+		return c.String(http.StatusNotFound, "")
+	})
+
+	fakeServer.HTTPErrorHandler = func(err error, c echo.Context) {
+		t.Errorf("methods=%s, path=%s, err=%s", c.Request().Method, c.Path(), err.Error())
+	}
+	server := httptest.NewServer(fakeServer)
+	os.Setenv("TSURU_TARGET", server.URL)
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: `
+data "acl_destination_rule" "app" {
+	instance = "my-acl"
+	app      = "my-destination-app"
+}
+				`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("data.acl_destination_rule.app", "id", "acl::my-acl::my-app-rule"),
+					resource.TestCheckResourceAttr("data.acl_destination_rule.app", "rule_id", "my-app-rule"),
+					resource.TestCheckResourceAttr("data.acl_destination_rule.app", "type", "app"),
+				),
+			},
+			{
+				Config: `
+data "acl_destination_rule" "missing" {
+	instance = "my-acl"
+	app      = "other-app"
+}
+				`,
+				ExpectError: regexp.MustCompile("Destination rule not found"),
+			},
+			{
+				Config: `
+data "acl_destination_rule" "dns" {
+	instance = "my-acl"
+	dns      = "example.org"
+}
+				`,
+				ExpectError: regexp.MustCompile("my-dns-rule-80, my-dns-rule-443"),
+			},
+		},
+	})
+}
@@ -0,0 +1,331 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/terraform-plugin-framework-validators/setvalidator"
+	"github.com/hashicorp/terraform-plugin-framework-validators/stringvalidator"
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	fwtypes "github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/tsuru/acl-api/api/types"
+	"github.com/tsuru/terraform-provider-acl/internal/acl"
+)
+
+var _ datasource.DataSourceWithConfigure = &destinationRulesDataSource{}
+
+type destinationRulesDataSource struct {
+	provider *aclProvider
+}
+
+type destinationRulesDataSourceModel struct {
+	ID               fwtypes.String         `tfsdk:"id"`
+	Instance         fwtypes.String         `tfsdk:"instance"`
+	ServiceName      fwtypes.String         `tfsdk:"service_name"`
+	DestinationTypes fwtypes.Set            `tfsdk:"destination_types"`
+	IncludeRemoved   fwtypes.Bool           `tfsdk:"include_removed"`
+	Rules            []destinationRuleModel `tfsdk:"rules"`
+}
+
+type destinationRuleModel struct {
+	RuleID            fwtypes.String           `tfsdk:"rule_id"`
+	Type              fwtypes.String           `tfsdk:"type"`
+	Name              fwtypes.String           `tfsdk:"name"`
+	Metadata          map[string]string        `tfsdk:"metadata"`
+	Creator           fwtypes.String           `tfsdk:"creator"`
+	CreatedAt         fwtypes.String           `tfsdk:"created_at"`
+	Removed           fwtypes.Bool             `tfsdk:"removed"`
+	App               fwtypes.String           `tfsdk:"app"`
+	Pool              fwtypes.String           `tfsdk:"pool"`
+	IP                fwtypes.String           `tfsdk:"ip"`
+	DNS               fwtypes.String           `tfsdk:"dns"`
+	Rpaas             []rpaasModel             `tfsdk:"rpaas"`
+	KubernetesService []kubernetesServiceModel `tfsdk:"kubernetes_service"`
+	Port              []portModel              `tfsdk:"port"`
+}
+
+type rpaasModel struct {
+	ServiceName fwtypes.String `tfsdk:"service_name"`
+	Instance    fwtypes.String `tfsdk:"instance"`
+}
+
+type kubernetesServiceModel struct {
+	Namespace   fwtypes.String `tfsdk:"namespace"`
+	ServiceName fwtypes.String `tfsdk:"service_name"`
+	Cluster     fwtypes.String `tfsdk:"cluster"`
+}
+
+type portModel struct {
+	Protocol fwtypes.String `tfsdk:"protocol"`
+	Number   fwtypes.Int64  `tfsdk:"number"`
+}
+
+func newDestinationRulesDataSource() datasource.DataSource {
+	return &destinationRulesDataSource{}
+}
+
+func (d *destinationRulesDataSource) Metadata(ctx context.Context, req datasource.MetadataRequest, resp *datasource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_destination_rules"
+}
+
+func (d *destinationRulesDataSource) Schema(ctx context.Context, req datasource.SchemaRequest, resp *datasource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		Description: "Lists the destination rules of an ACL service instance",
+		Attributes: map[string]schema.Attribute{
+			"id": schema.StringAttribute{
+				Computed:    true,
+				Description: "<SERVICE>::<INSTANCE>",
+			},
+			"instance": schema.StringAttribute{
+				Required:    true,
+				Description: "ACL Instance Name",
+			},
+			"service_name": schema.StringAttribute{
+				Optional:    true,
+				Computed:    true,
+				Description: "ACL Service Name, defaults to acl",
+			},
+			"destination_types": schema.SetAttribute{
+				Optional:    true,
+				ElementType: fwtypes.StringType,
+				Description: "Only list rules with these destination types (app, pool, rpaas, ip, dns, kubernetes_service)",
+				Validators: []validator.Set{
+					setvalidator.ValueStringsAre(stringvalidator.OneOf(acl.Destinations...)),
+				},
+			},
+			"include_removed": schema.BoolAttribute{
+				Optional:    true,
+				Computed:    true,
+				Description: "Also list rules flagged as removed, defaults to false",
+			},
+			"rules": schema.ListNestedAttribute{
+				Computed:    true,
+				Description: "Destination rules of the instance",
+				NestedObject: schema.NestedAttributeObject{
+					Attributes: destinationRuleAttributes(),
+				},
+			},
+		},
+	}
+}
+
+// destinationRuleAttributes describes a rule as exposed by data sources, with
+// the same types as destinationRuleDataSchema.
+func destinationRuleAttributes() map[string]schema.Attribute {
+	return map[string]schema.Attribute{
+		"rule_id": schema.StringAttribute{
+			Computed:    true,
+			Description: "Rule ID",
+		},
+		"type": schema.StringAttribute{
+			Computed:    true,
+			Description: "Destination type (app, pool, rpaas, ip, dns, kubernetes_service)",
+		},
+		"name": schema.StringAttribute{
+			Computed:    true,
+			Description: "Rule name",
+		},
+		"metadata": schema.MapAttribute{
+			Computed:    true,
+			ElementType: fwtypes.StringType,
+			Description: "Rule metadata",
+		},
+		"creator": schema.StringAttribute{
+			Computed:    true,
+			Description: "User who created the rule",
+		},
+		"created_at": schema.StringAttribute{
+			Computed:    true,
+			Description: "Rule creation time (RFC3339)",
+		},
+		"removed": schema.BoolAttribute{
+			Computed:    true,
+			Description: "Whether the rule was removed",
+		},
+		"app": schema.StringAttribute{
+			Computed:    true,
+			Description: "Destination tsuru app name",
+		},
+		"pool": schema.StringAttribute{
+			Computed:    true,
+			Description: "Tsuru Pool name",
+		},
+		"ip": schema.StringAttribute{
+			Computed:    true,
+			Description: "Destination IP address",
+		},
+		"dns": schema.StringAttribute{
+			Computed:    true,
+			Description: "Destination fully qualified domain name (FQDN)",
+		},
+		"rpaas": schema.ListNestedAttribute{
+			Computed:    true,
+			Description: "Destination tsuru rpaas name",
+			NestedObject: schema.NestedAttributeObject{
+				Attributes: map[string]schema.Attribute{
+					"service_name": schema.StringAttribute{
+						Computed:    true,
+						Description: "Destination rpaas service name",
+					},
+					"instance": schema.StringAttribute{
+						Computed:    true,
+						Description: "Destination rpaas instance name",
+					},
+				},
+			},
+		},
+		"kubernetes_service": schema.ListNestedAttribute{
+			Computed:    true,
+			Description: "Destination kubernetes service",
+			NestedObject: schema.NestedAttributeObject{
+				Attributes: map[string]schema.Attribute{
+					"namespace": schema.StringAttribute{
+						Computed:    true,
+						Description: "Destination kubernetes namespace",
+					},
+					"service_name": schema.StringAttribute{
+						Computed:    true,
+						Description: "Destination kubernetes service name",
+					},
+					"cluster": schema.StringAttribute{
+						Computed:    true,
+						Description: "Destination kubernetes cluster name",
+					},
+				},
+			},
+		},
+		"port": schema.ListNestedAttribute{
+			Computed:    true,
+			Description: "Destination port and protocol list",
+			NestedObject: schema.NestedAttributeObject{
+				Attributes: map[string]schema.Attribute{
+					"protocol": schema.StringAttribute{
+						Computed:    true,
+						Description: "Procotol name",
+					},
+					"number": schema.Int64Attribute{
+						Computed:    true,
+						Description: "Port number",
+					},
+				},
+			},
+		},
+	}
+}
+
+func (d *destinationRulesDataSource) Configure(ctx context.Context, req datasource.ConfigureRequest, resp *datasource.ConfigureResponse) {
+	if req.ProviderData == nil {
+		return
+	}
+
+	provider, ok := req.ProviderData.(*aclProvider)
+	if !ok {
+		resp.Diagnostics.AddError("Unexpected provider data", fmt.Sprintf("expected *aclProvider, got %T", req.ProviderData))
+		return
+	}
+	d.provider = provider
+}
+
+func (d *destinationRulesDataSource) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
+	var state destinationRulesDataSourceModel
+	resp.Diagnostics.Append(req.Config.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	if state.ServiceName.IsNull() {
+		state.ServiceName = fwtypes.StringValue("acl")
+	}
+	if state.IncludeRemoved.IsNull() {
+		state.IncludeRemoved = fwtypes.BoolValue(false)
+	}
+
+	var destinationTypeList []string
+	resp.Diagnostics.Append(state.DestinationTypes.ElementsAs(ctx, &destinationTypeList, false)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	destinationTypes := map[string]bool{}
+	for _, destinationType := range destinationTypeList {
+		destinationTypes[destinationType] = true
+	}
+
+	serviceName := state.ServiceName.ValueString()
+	instance := state.Instance.ValueString()
+
+	rules, err := d.provider.client.DestinationRules(ctx, serviceName, instance)
+	if err != nil {
+		resp.Diagnostics.AddError("DestinationRules", err.Error())
+		return
+	}
+
+	if !state.IncludeRemoved.ValueBool() {
+		rules = acl.ActiveRules(rules)
+	}
+
+	state.Rules = []destinationRuleModel{}
+	for i := range rules {
+		if len(destinationTypes) > 0 && !destinationTypes[acl.RuleDestinationType(&rules[i])] {
+			continue
+		}
+		state.Rules = append(state.Rules, newDestinationRuleModel(&rules[i]))
+	}
+
+	state.ID = fwtypes.StringValue(acl.GenerateID([]string{serviceName, instance}))
+	resp.Diagnostics.Append(resp.State.Set(ctx, &state)...)
+}
+
+// newDestinationRuleModel flattens a rule, destination attributes of other
+// types are left null instead of empty.
+func newDestinationRuleModel(rule *types.Rule) destinationRuleModel {
+	model := destinationRuleModel{
+		RuleID:    fwtypes.StringValue(rule.RuleID),
+		Type:      fwtypes.StringValue(acl.RuleDestinationType(rule)),
+		Name:      fwtypes.StringValue(rule.RuleName),
+		Metadata:  rule.Metadata,
+		Creator:   fwtypes.StringValue(rule.Creator),
+		CreatedAt: fwtypes.StringValue(flattenTime(rule.Created)),
+		Removed:   fwtypes.BoolValue(rule.Removed),
+		App:       fwtypes.StringNull(),
+		Pool:      fwtypes.StringNull(),
+		IP:        fwtypes.StringNull(),
+		DNS:       fwtypes.StringNull(),
+	}
+
+	destination := rule.Destination
+	var ports []types.ProtoPort
+	switch acl.RuleDestinationType(rule) {
+	case acl.DestinationApp:
+		model.App = fwtypes.StringValue(destination.TsuruApp.AppName)
+	case acl.DestinationPool:
+		model.Pool = fwtypes.StringValue(destination.TsuruApp.PoolName)
+	case acl.DestinationCIDR:
+		model.IP = fwtypes.StringValue(acl.NormalizeCIDR(destination.ExternalIP.IP))
+		ports = destination.ExternalIP.Ports
+	case acl.DestinationDNS:
+		model.DNS = fwtypes.StringValue(acl.NormalizeDNS(destination.ExternalDNS.Name))
+		ports = destination.ExternalDNS.Ports
+	case acl.DestinationRpaaS:
+		model.Rpaas = []rpaasModel{{
+			ServiceName: fwtypes.StringValue(destination.RpaasInstance.ServiceName),
+			Instance:    fwtypes.StringValue(destination.RpaasInstance.Instance),
+		}}
+	case acl.DestinationKubernetesService:
+		model.KubernetesService = []kubernetesServiceModel{{
+			Namespace:   fwtypes.StringValue(acl.KubernetesNamespace(destination.KubernetesService.Namespace)),
+			ServiceName: fwtypes.StringValue(destination.KubernetesService.ServiceName),
+			Cluster:     fwtypes.StringValue(destination.KubernetesService.ClusterName),
+		}}
+	}
+
+	for _, port := range ports {
+		model.Port = append(model.Port, portModel{
+			Protocol: fwtypes.StringValue(port.Protocol),
+			Number:   fwtypes.Int64Value(int64(port.Port)),
+		})
+	}
+
+	return model
+}
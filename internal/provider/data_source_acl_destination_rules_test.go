@@ -0,0 +1,106 @@
+// Copyright 2021 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package provider
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	echo "github.com/labstack/echo/v4"
+	"github.com/tsuru/acl-api/api/types"
+	"github.com/tsuru/terraform-provider-acl/internal/acl"
+)
+
+func TestAccDataSourceDestinationRules(t *testing.T) {
+	fakeServer := echo.New()
+	rules := []types.ServiceRule{
+		{
+			Creator: "user@example.org",
+			Rule: types.Rule{
+				RuleID:   "my-app-rule",
+				RuleName: "my app rule",
+				Destination: types.RuleType{
+					TsuruApp: &types.TsuruAppRule{
+						AppName: "my-destination-app",
+					},
+				},
+			},
+		},
+		{
+			Rule: types.Rule{
+				RuleID: "my-dns-rule",
+				Destination: types.RuleType{
+					ExternalDNS: &types.ExternalDNSRule{
+						Name: "example.org",
+						Ports: []types.ProtoPort{
+							{
+								Protocol: "TCP",
+								Port:     443,
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	fakeServer.Any("/services/acl/proxy/:instance", func(c echo.Context) error {
+		callback := c.QueryParam("callback")
+		if callback == "/rule" && c.Request().Method == http.MethodGet {
+			return c.JSON(http.StatusOK, &acl.ServiceRuleData{
+				ServiceInstance: types.ServiceInstance{
+					BaseRules: rules,
+				},
+			})
+		}
+		t.Fatalf("method=%q, path=%q, callback=%q, err=\"Not found\"",
+			c.Request().Method,
+			c.Path(),
+			callback,
+		)
+		return c.String(http.StatusNotFound, "")
+	})
+
+	fakeServer.HTTPErrorHandler = func(err error, c echo.Context) {
+		t.Errorf("methods=%s, path=%s, err=%s", c.Request().Method, c.Path(), err.Error())
+	}
+	server := httptest.NewServer(fakeServer)
+	os.Setenv("TSURU_TARGET", server.URL)
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: `
+data "acl_destination_rules" "all" {
+	instance = "my-acl"
+}
+
+data "acl_destination_rules" "dns" {
+	instance          = "my-acl"
+	destination_types = ["dns"]
+}
+				`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("data.acl_destination_rules.all", "id", "acl::my-acl"),
+					resource.TestCheckResourceAttr("data.acl_destination_rules.all", "rules.#", "2"),
+					resource.TestCheckResourceAttr("data.acl_destination_rules.all", "rules.0.rule_id", "my-app-rule"),
+					resource.TestCheckResourceAttr("data.acl_destination_rules.all", "rules.0.type", "app"),
+					resource.TestCheckResourceAttr("data.acl_destination_rules.all", "rules.0.app", "my-destination-app"),
+					resource.TestCheckResourceAttr("data.acl_destination_rules.all", "rules.0.name", "my app rule"),
+					resource.TestCheckResourceAttr("data.acl_destination_rules.all", "rules.0.creator", "user@example.org"),
+					resource.TestCheckResourceAttr("data.acl_destination_rules.dns", "rules.#", "1"),
+					resource.TestCheckResourceAttr("data.acl_destination_rules.dns", "rules.0.rule_id", "my-dns-rule"),
+					resource.TestCheckResourceAttr("data.acl_destination_rules.dns", "rules.0.dns", "example.org"),
+					resource.TestCheckResourceAttr("data.acl_destination_rules.dns", "rules.0.port.0.number", "443"),
+				),
+			},
+		},
+	})
+}
@@ -0,0 +1,236 @@
+package provider
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/provider"
+	"github.com/hashicorp/terraform-plugin-framework/provider/schema"
+	"github.com/hashicorp/terraform-plugin-framework/providerserver"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	fwtypes "github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-go/tfprotov6"
+	"github.com/hashicorp/terraform-plugin-mux/tf5to6server"
+	"github.com/hashicorp/terraform-plugin-mux/tf6muxserver"
+	"github.com/tsuru/terraform-provider-acl/internal/acl"
+)
+
+// NewProviderServer returns the protocol 6 server of the provider, muxing the
+// resources still implemented with the SDK and the ones implemented with the
+// plugin framework.
+func NewProviderServer(ctx context.Context, version string) (func() tfprotov6.ProviderServer, error) {
+	shared := &sharedACLProvider{}
+
+	sdkServer, err := tf5to6server.UpgradeServer(ctx, newProvider(shared).GRPCProvider)
+	if err != nil {
+		return nil, err
+	}
+
+	muxServer, err := tf6muxserver.NewMuxServer(ctx,
+		func() tfprotov6.ProviderServer { return sdkServer },
+		providerserver.NewProtocol6(&frameworkProvider{version: version, shared: shared}),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return muxServer.ProviderServer, nil
+}
+
+var _ provider.Provider = &frameworkProvider{}
+
+type frameworkProvider struct {
+	version string
+	shared  *sharedACLProvider
+}
+
+type frameworkProviderModel struct {
+	Host                 fwtypes.String        `tfsdk:"host"`
+	Token                fwtypes.String        `tfsdk:"token"`
+	SkipCertVerification fwtypes.Bool          `tfsdk:"skip_cert_verification"`
+	CACertFile           fwtypes.String        `tfsdk:"ca_cert_file"`
+	CACert               fwtypes.String        `tfsdk:"ca_cert"`
+	ClientCertFile       fwtypes.String        `tfsdk:"client_cert_file"`
+	ClientKeyFile        fwtypes.String        `tfsdk:"client_key_file"`
+	ClientCert           fwtypes.String        `tfsdk:"client_cert"`
+	ClientKey            fwtypes.String        `tfsdk:"client_key"`
+	RequestTimeout       fwtypes.Int64         `tfsdk:"request_timeout"`
+	DefaultMetadata      fwtypes.Map           `tfsdk:"default_metadata"`
+	Retry                []frameworkRetryModel `tfsdk:"retry"`
+}
+
+type frameworkRetryModel struct {
+	MaxAttempts fwtypes.Int64  `tfsdk:"max_attempts"`
+	MinBackoff  fwtypes.String `tfsdk:"min_backoff"`
+	MaxBackoff  fwtypes.String `tfsdk:"max_backoff"`
+}
+
+// NewFrameworkProvider returns the part of the provider implemented with the
+// plugin framework, its schema must be kept equal to the SDK provider schema.
+func NewFrameworkProvider(version string) func() provider.Provider {
+	return func() provider.Provider {
+		return &frameworkProvider{version: version, shared: &sharedACLProvider{}}
+	}
+}
+
+func (p *frameworkProvider) Metadata(ctx context.Context, req provider.MetadataRequest, resp *provider.MetadataResponse) {
+	resp.TypeName = "acl"
+	resp.Version = p.version
+}
+
+func (p *frameworkProvider) Schema(ctx context.Context, req provider.SchemaRequest, resp *provider.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		Attributes: map[string]schema.Attribute{
+			"host": schema.StringAttribute{
+				Description: "Target to tsuru API",
+				Optional:    true,
+			},
+			"token": schema.StringAttribute{
+				Description: "Token to authenticate on tsuru API (optional)",
+				Optional:    true,
+			},
+			"skip_cert_verification": schema.BoolAttribute{
+				Description: "Disable certificate verification",
+				Optional:    true,
+			},
+			"ca_cert_file": schema.StringAttribute{
+				Description: "Path to a PEM bundle of certificate authorities trusted to verify tsuru API",
+				Optional:    true,
+			},
+			"ca_cert": schema.StringAttribute{
+				Description: "PEM encoded certificate authorities trusted to verify tsuru API",
+				Optional:    true,
+			},
+			"client_cert_file": schema.StringAttribute{
+				Description: "Path to a PEM encoded client certificate used for mutual TLS",
+				Optional:    true,
+			},
+			"client_key_file": schema.StringAttribute{
+				Description: "Path to a PEM encoded client key used for mutual TLS",
+				Optional:    true,
+			},
+			"client_cert": schema.StringAttribute{
+				Description: "PEM encoded client certificate used for mutual TLS",
+				Optional:    true,
+			},
+			"client_key": schema.StringAttribute{
+				Description: "PEM encoded client key used for mutual TLS",
+				Optional:    true,
+				Sensitive:   true,
+			},
+			"request_timeout": schema.Int64Attribute{
+				Description: "Timeout in seconds of each request to tsuru API, 0 means no timeout",
+				Optional:    true,
+			},
+			"default_metadata": schema.MapAttribute{
+				Description: "Metadata added to every rule created by this provider, keys set on the resource take precedence, changing it does not replace existing rules",
+				Optional:    true,
+				ElementType: fwtypes.StringType,
+			},
+		},
+		Blocks: map[string]schema.Block{
+			"retry": schema.ListNestedBlock{
+				Description: "Retry policy of requests failing with transient errors (429, 502, 503, 504 and connection resets), rules are only created again on 429 and 503",
+				NestedObject: schema.NestedBlockObject{
+					Attributes: map[string]schema.Attribute{
+						"max_attempts": schema.Int64Attribute{
+							Description: "Maximum number of attempts of each request, 1 disables retries",
+							Optional:    true,
+						},
+						"min_backoff": schema.StringAttribute{
+							Description: "Minimum time to wait between attempts (ex: 500ms, 1s)",
+							Optional:    true,
+						},
+						"max_backoff": schema.StringAttribute{
+							Description: "Maximum time to wait between attempts (ex: 30s, 1m)",
+							Optional:    true,
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// Configure builds the same client as the SDK provider, the configuration is
+// validated by the SDK provider. Both get the same client when they are served
+// together by NewProviderServer.
+func (p *frameworkProvider) Configure(ctx context.Context, req provider.ConfigureRequest, resp *provider.ConfigureResponse) {
+	var config frameworkProviderModel
+	resp.Diagnostics.Append(req.Config.Get(ctx, &config)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	skipCertVerification := config.SkipCertVerification.ValueBool()
+	if config.SkipCertVerification.IsNull() {
+		skipCertVerification, _ = strconv.ParseBool(os.Getenv("TSURU_SKIP_CERT_VERIFICATION"))
+	}
+
+	opts := acl.ClientOptions{
+		SkipCertVerification: skipCertVerification,
+		CACertFile:           config.CACertFile.ValueString(),
+		CACert:               config.CACert.ValueString(),
+		ClientCertFile:       config.ClientCertFile.ValueString(),
+		ClientKeyFile:        config.ClientKeyFile.ValueString(),
+		ClientCert:           config.ClientCert.ValueString(),
+		ClientKey:            config.ClientKey.ValueString(),
+		Timeout:              time.Duration(config.RequestTimeout.ValueInt64()) * time.Second,
+		Retry:                acl.DefaultRetryPolicy,
+	}
+
+	if len(config.Retry) > 0 {
+		retry := config.Retry[0]
+		if !retry.MaxAttempts.IsNull() {
+			opts.Retry.MaxAttempts = int(retry.MaxAttempts.ValueInt64())
+		}
+		if !retry.MinBackoff.IsNull() {
+			minBackoff, err := time.ParseDuration(retry.MinBackoff.ValueString())
+			if err != nil {
+				resp.Diagnostics.AddAttributeError(path.Root("retry").AtListIndex(0).AtName("min_backoff"), "Invalid duration", err.Error())
+			}
+			opts.Retry.MinBackoff = minBackoff
+		}
+		if !retry.MaxBackoff.IsNull() {
+			maxBackoff, err := time.ParseDuration(retry.MaxBackoff.ValueString())
+			if err != nil {
+				resp.Diagnostics.AddAttributeError(path.Root("retry").AtListIndex(0).AtName("max_backoff"), "Invalid duration", err.Error())
+			}
+			opts.Retry.MaxBackoff = maxBackoff
+		}
+	}
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	defaultMetadata := map[string]string{}
+	resp.Diagnostics.Append(config.DefaultMetadata.ElementsAs(ctx, &defaultMetadata, false)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	aclProvider, err := p.shared.get(ctx, config.Host.ValueString(), config.Token.ValueString(), opts, defaultMetadata)
+	if err != nil {
+		resp.Diagnostics.AddError("Unable to create acl client", err.Error())
+		return
+	}
+
+	resp.DataSourceData = aclProvider
+	resp.ResourceData = aclProvider
+}
+
+func (p *frameworkProvider) Resources(ctx context.Context) []func() resource.Resource {
+	return []func() resource.Resource{
+		newDestinationRuleResource,
+	}
+}
+
+func (p *frameworkProvider) DataSources(ctx context.Context) []func() datasource.DataSource {
+	return []func() datasource.DataSource{
+		newDestinationRulesDataSource,
+	}
+}
@@ -0,0 +1,198 @@
+package provider
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/tsuru/acl-api/api/types"
+	"github.com/tsuru/terraform-provider-acl/internal/acl"
+)
+
+var invalidLabelChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+// GenerateConfig writes an acl_destination_rule resource and its import block
+// for each active rule, so an existing instance can be brought under
+// Terraform with a single apply.
+func GenerateConfig(w io.Writer, serviceName, instance string, rules []types.Rule) error {
+	used := map[string]bool{}
+	for i, rule := range acl.ActiveRules(rules) {
+		rule := rule
+		base := resourceLabel(&rule)
+		label := base
+		for n := 2; used[label]; n++ {
+			label = fmt.Sprintf("%s_%d", base, n)
+		}
+		used[label] = true
+
+		config := generateRule(label, serviceName, instance, &rule)
+		if i > 0 {
+			config = "\n" + config
+		}
+		if _, err := io.WriteString(w, config); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func generateRule(label, serviceName, instance string, rule *types.Rule) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "import {\n")
+	fmt.Fprintf(&b, "  to = acl_destination_rule.%s\n", label)
+	fmt.Fprintf(&b, "  id = %s\n", hclString(acl.GenerateID([]string{serviceName, instance, rule.RuleID})))
+	fmt.Fprintf(&b, "}\n\n")
+
+	fmt.Fprintf(&b, "resource \"acl_destination_rule\" %q {\n", label)
+	var attributes [][2]string
+	if serviceName != "acl" {
+		attributes = append(attributes, [2]string{"service_name", hclString(serviceName)})
+	}
+	attributes = append(attributes, [2]string{"instance", hclString(instance)})
+	if rule.RuleName != "" {
+		attributes = append(attributes, [2]string{"name", hclString(rule.RuleName)})
+	}
+	writeAttributes(&b, "  ", attributes)
+	if len(rule.Metadata) > 0 {
+		keys := make([]string, 0, len(rule.Metadata))
+		for key := range rule.Metadata {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		metadata := make([][2]string, 0, len(keys))
+		for _, key := range keys {
+			metadata = append(metadata, [2]string{hclString(key), hclString(rule.Metadata[key])})
+		}
+		fmt.Fprintf(&b, "\n  metadata = {\n")
+		writeAttributes(&b, "    ", metadata)
+		fmt.Fprintf(&b, "  }\n")
+	}
+	b.WriteString("\n")
+
+	destination := rule.Destination
+	var ports []types.ProtoPort
+	switch acl.RuleDestinationType(rule) {
+	case acl.DestinationApp:
+		fmt.Fprintf(&b, "  app = %s\n", hclString(destination.TsuruApp.AppName))
+	case acl.DestinationPool:
+		fmt.Fprintf(&b, "  pool = %s\n", hclString(destination.TsuruApp.PoolName))
+	case acl.DestinationCIDR:
+		fmt.Fprintf(&b, "  ip = %s\n", hclString(acl.NormalizeCIDR(destination.ExternalIP.IP)))
+		ports = destination.ExternalIP.Ports
+	case acl.DestinationDNS:
+		fmt.Fprintf(&b, "  dns = %s\n", hclString(acl.NormalizeDNS(destination.ExternalDNS.Name)))
+		ports = destination.ExternalDNS.Ports
+	case acl.DestinationRpaaS:
+		fmt.Fprintf(&b, "  rpaas = {\n")
+		fmt.Fprintf(&b, "    service_name = %s\n", hclString(destination.RpaasInstance.ServiceName))
+		fmt.Fprintf(&b, "    instance     = %s\n", hclString(destination.RpaasInstance.Instance))
+		fmt.Fprintf(&b, "  }\n")
+	case acl.DestinationKubernetesService:
+		fmt.Fprintf(&b, "  kubernetes_service = {\n")
+		fmt.Fprintf(&b, "    namespace    = %s\n", hclString(acl.KubernetesNamespace(destination.KubernetesService.Namespace)))
+		fmt.Fprintf(&b, "    service_name = %s\n", hclString(destination.KubernetesService.ServiceName))
+		if destination.KubernetesService.ClusterName != "" {
+			fmt.Fprintf(&b, "    cluster      = %s\n", hclString(destination.KubernetesService.ClusterName))
+		}
+		fmt.Fprintf(&b, "  }\n")
+	}
+
+	if portBlocks := flattenPortBlocks(ports, nil); len(portBlocks) > 0 {
+		fmt.Fprintf(&b, "\n  port = [\n")
+		for _, port := range portBlocks {
+			portMap := port.(map[string]interface{})
+			fmt.Fprintf(&b, "    {\n")
+			if number, ok := portMap["number"]; ok {
+				fmt.Fprintf(&b, "      number   = %d\n", number)
+			} else {
+				fmt.Fprintf(&b, "      from     = %d\n", portMap["from"])
+				fmt.Fprintf(&b, "      to       = %d\n", portMap["to"])
+			}
+			fmt.Fprintf(&b, "      protocol = %s\n", hclString(portMap["protocol"].(string)))
+			fmt.Fprintf(&b, "    },\n")
+		}
+		fmt.Fprintf(&b, "  ]\n")
+	}
+
+	fmt.Fprintf(&b, "}\n")
+
+	return b.String()
+}
+
+// resourceLabel returns a valid Terraform resource name for the rule, based on
+// its name or, when it has none, on its destination.
+func resourceLabel(rule *types.Rule) string {
+	value := rule.RuleName
+	if value == "" {
+		parts := acl.ParseIDParts(acl.DestinationKey(rule))
+		if destinationType := acl.RuleDestinationType(rule); (destinationType == acl.DestinationCIDR || destinationType == acl.DestinationDNS) && len(parts) > 2 {
+			// ports are not part of the label
+			parts = parts[:2]
+		}
+		value = strings.Join(parts, "_")
+	}
+
+	label := strings.Trim(invalidLabelChars.ReplaceAllString(strings.ToLower(value), "_"), "_")
+	if label == "" {
+		label = "rule"
+	}
+	if label[0] >= '0' && label[0] <= '9' {
+		label = "rule_" + label
+	}
+
+	return label
+}
+
+// writeAttributes writes one attribute per line with the equal signs aligned,
+// as terraform fmt does.
+func writeAttributes(b *strings.Builder, indent string, attributes [][2]string) {
+	width := 0
+	for _, attribute := range attributes {
+		if len(attribute[0]) > width {
+			width = len(attribute[0])
+		}
+	}
+	for _, attribute := range attributes {
+		fmt.Fprintf(b, "%s%-*s = %s\n", indent, width, attribute[0], attribute[1])
+	}
+}
+
+// hclString quotes s as an HCL string, escaping template sequences. HCL has
+// no \x, \a or \v escapes, other non-printable characters are written as
+// \uNNNN (or \UNNNNNNNN).
+func hclString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch {
+		case r == '"':
+			b.WriteString(`\"`)
+		case r == '\\':
+			b.WriteString(`\\`)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case unicode.IsPrint(r):
+			b.WriteRune(r)
+		case r > 0xffff:
+			fmt.Fprintf(&b, `\U%08x`, r)
+		default:
+			fmt.Fprintf(&b, `\u%04x`, r)
+		}
+	}
+	b.WriteByte('"')
+
+	quoted := b.String()
+	quoted = strings.ReplaceAll(quoted, "${", "$${")
+	quoted = strings.ReplaceAll(quoted, "%{", "%%{")
+	return quoted
+}
@@ -0,0 +1,148 @@
+// Copyright 2021 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package provider
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tsuru/acl-api/api/types"
+)
+
+func TestGenerateConfig(t *testing.T) {
+	rules := []types.Rule{
+		{
+			RuleID:   "rule-1",
+			RuleName: "example-org",
+			Metadata: map[string]string{"ticket": "INFRA-1", "owner": "team-a"},
+			Destination: types.RuleType{
+				ExternalDNS: &types.ExternalDNSRule{
+					Name: "example.org",
+					Ports: []types.ProtoPort{
+						{Protocol: "TCP", Port: 443},
+						{Protocol: "TCP", Port: 30000},
+						{Protocol: "TCP", Port: 30001},
+					},
+				},
+			},
+		},
+		{
+			RuleID: "rule-2",
+			Destination: types.RuleType{
+				TsuruApp: &types.TsuruAppRule{AppName: "my-app"},
+			},
+		},
+		{
+			RuleID:  "rule-3",
+			Removed: true,
+			Destination: types.RuleType{
+				TsuruApp: &types.TsuruAppRule{AppName: "removed-app"},
+			},
+		},
+		{
+			RuleID: "rule-4",
+			Destination: types.RuleType{
+				RpaasInstance: &types.RpaasInstanceRule{ServiceName: "rpaasv2-be", Instance: "${my-rpaas}"},
+			},
+		},
+		{
+			RuleID: "rule-5",
+			Destination: types.RuleType{
+				TsuruApp: &types.TsuruAppRule{AppName: "my-app"},
+			},
+		},
+	}
+
+	var out bytes.Buffer
+	require.NoError(t, GenerateConfig(&out, "acl-dev", "my-acl", rules))
+	require.Equal(t, `import {
+  to = acl_destination_rule.example_org
+  id = "acl-dev::my-acl::rule-1"
+}
+
+resource "acl_destination_rule" "example_org" {
+  service_name = "acl-dev"
+  instance     = "my-acl"
+  name         = "example-org"
+
+  metadata = {
+    "owner"  = "team-a"
+    "ticket" = "INFRA-1"
+  }
+
+  dns = "example.org"
+
+  port = [
+    {
+      number   = 443
+      protocol = "TCP"
+    },
+    {
+      from     = 30000
+      to       = 30001
+      protocol = "TCP"
+    },
+  ]
+}
+
+import {
+  to = acl_destination_rule.app_my_app
+  id = "acl-dev::my-acl::rule-2"
+}
+
+resource "acl_destination_rule" "app_my_app" {
+  service_name = "acl-dev"
+  instance     = "my-acl"
+
+  app = "my-app"
+}
+
+import {
+  to = acl_destination_rule.rpaas_rpaasv2_be_my_rpaas
+  id = "acl-dev::my-acl::rule-4"
+}
+
+resource "acl_destination_rule" "rpaas_rpaasv2_be_my_rpaas" {
+  service_name = "acl-dev"
+  instance     = "my-acl"
+
+  rpaas = {
+    service_name = "rpaasv2-be"
+    instance     = "$${my-rpaas}"
+  }
+}
+
+import {
+  to = acl_destination_rule.app_my_app_2
+  id = "acl-dev::my-acl::rule-5"
+}
+
+resource "acl_destination_rule" "app_my_app_2" {
+  service_name = "acl-dev"
+  instance     = "my-acl"
+
+  app = "my-app"
+}
+`, out.String())
+}
+
+func TestHCLString(t *testing.T) {
+	tests := map[string]string{
+		"my-app":               `"my-app"`,
+		`say "hi"`:             `"say \"hi\""`,
+		`C:\acl`:               `"C:\\acl"`,
+		"line\r\nnext\tcolumn": `"line\r\nnext\tcolumn"`,
+		"\x1b[0m":              `"\u001b[0m"`,
+		"bell\a tab\v":         `"bell\u0007 tab\u000b"`,
+		"\u2028":               `"\u2028"`,
+		"\U000e0001":           `"\U000e0001"`,
+		"ação":                 `"ação"`,
+		"${var.name} %{if}":    `"$${var.name} %%{if}"`,
+	}
+	for value, expected := range tests {
+		require.Equal(t, expected, hclString(value), value)
+	}
+}
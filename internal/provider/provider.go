@@ -2,13 +2,22 @@ package provider
 
 import (
 	"context"
+	"maps"
+	"sync"
+	"time"
 
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+	"github.com/tsuru/acl-api/api/types"
 	"github.com/tsuru/terraform-provider-acl/internal/acl"
 )
 
 func Provider() *schema.Provider {
+	return newProvider(&sharedACLProvider{})
+}
+
+func newProvider(shared *sharedACLProvider) *schema.Provider {
 	p := &schema.Provider{
 		Schema: map[string]*schema.Schema{
 			"host": {
@@ -24,17 +33,104 @@ func Provider() *schema.Provider {
 			"skip_cert_verification": {
 				Type:        schema.TypeBool,
 				Description: "Disable certificate verification",
-				Default:     false,
 				Optional:    true,
-				DefaultFunc: schema.EnvDefaultFunc("TSURU_SKIP_CERT_VERIFICATION", nil),
+				DefaultFunc: schema.EnvDefaultFunc("TSURU_SKIP_CERT_VERIFICATION", false),
+			},
+			"ca_cert_file": {
+				Type:          schema.TypeString,
+				Description:   "Path to a PEM bundle of certificate authorities trusted to verify tsuru API",
+				Optional:      true,
+				ConflictsWith: []string{"ca_cert"},
+			},
+			"ca_cert": {
+				Type:          schema.TypeString,
+				Description:   "PEM encoded certificate authorities trusted to verify tsuru API",
+				Optional:      true,
+				ConflictsWith: []string{"ca_cert_file"},
+			},
+			"client_cert_file": {
+				Type:          schema.TypeString,
+				Description:   "Path to a PEM encoded client certificate used for mutual TLS",
+				Optional:      true,
+				ConflictsWith: []string{"client_cert"},
+				RequiredWith:  []string{"client_key_file"},
+			},
+			"client_key_file": {
+				Type:          schema.TypeString,
+				Description:   "Path to a PEM encoded client key used for mutual TLS",
+				Optional:      true,
+				ConflictsWith: []string{"client_key"},
+				RequiredWith:  []string{"client_cert_file"},
+			},
+			"client_cert": {
+				Type:         schema.TypeString,
+				Description:  "PEM encoded client certificate used for mutual TLS",
+				Optional:     true,
+				RequiredWith: []string{"client_key"},
+			},
+			"client_key": {
+				Type:         schema.TypeString,
+				Description:  "PEM encoded client key used for mutual TLS",
+				Optional:     true,
+				Sensitive:    true,
+				RequiredWith: []string{"client_cert"},
+			},
+			"request_timeout": {
+				Type:         schema.TypeInt,
+				Description:  "Timeout in seconds of each request to tsuru API, 0 means no timeout",
+				Optional:     true,
+				Default:      0,
+				ValidateFunc: validation.IntAtLeast(0),
+			},
+			"default_metadata": {
+				Type:        schema.TypeMap,
+				Description: "Metadata added to every rule created by this provider, keys set on the resource take precedence, changing it does not replace existing rules",
+				Optional:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+			},
+			"retry": {
+				Type:        schema.TypeList,
+				Description: "Retry policy of requests failing with transient errors (429, 502, 503, 504 and connection resets), rules are only created again on 429 and 503",
+				Optional:    true,
+				MaxItems:    1,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"max_attempts": {
+							Type:         schema.TypeInt,
+							Description:  "Maximum number of attempts of each request, 1 disables retries",
+							Optional:     true,
+							Default:      acl.DefaultRetryPolicy.MaxAttempts,
+							ValidateFunc: validation.IntAtLeast(1),
+						},
+						"min_backoff": {
+							Type:         schema.TypeString,
+							Description:  "Minimum time to wait between attempts (ex: 500ms, 1s)",
+							Optional:     true,
+							Default:      acl.DefaultRetryPolicy.MinBackoff.String(),
+							ValidateFunc: validateDuration,
+						},
+						"max_backoff": {
+							Type:         schema.TypeString,
+							Description:  "Maximum time to wait between attempts (ex: 30s, 1m)",
+							Optional:     true,
+							Default:      acl.DefaultRetryPolicy.MaxBackoff.String(),
+							ValidateFunc: validateDuration,
+						},
+					},
+				},
 			},
 		},
+		// acl_destination_rule and acl_destination_rules are served by the
+		// plugin framework provider
 		ResourcesMap: map[string]*schema.Resource{
-			"acl_destination_rule": resourceACLDestinationRule(),
+			"acl_destination_rule_set": resourceACLDestinationRuleSet(),
+		},
+		DataSourcesMap: map[string]*schema.Resource{
+			"acl_destination_rule": dataSourceACLDestinationRule(),
 		},
 	}
 	p.ConfigureContextFunc = func(ctx context.Context, d *schema.ResourceData) (interface{}, diag.Diagnostics) {
-		return providerConfigure(ctx, d, p.TerraformVersion)
+		return providerConfigure(ctx, d, shared, p.TerraformVersion)
 	}
 	return p
 }
@@ -42,20 +138,111 @@ func Provider() *schema.Provider {
 type aclProvider struct {
 	client           acl.Client
 	terraformVersion string
+	defaultMetadata  map[string]string
+}
+
+// applyDefaultMetadata merges the provider default metadata into the rule,
+// keys already set on the rule take precedence.
+func (p *aclProvider) applyDefaultMetadata(rule *types.Rule) {
+	if len(p.defaultMetadata) == 0 {
+		return
+	}
+
+	metadata := map[string]string{}
+	for key, value := range p.defaultMetadata {
+		metadata[key] = value
+	}
+	for key, value := range rule.Metadata {
+		metadata[key] = value
+	}
+	rule.Metadata = metadata
 }
 
-func providerConfigure(ctx context.Context, d *schema.ResourceData, terraformVersion string) (interface{}, diag.Diagnostics) {
+func providerConfigure(ctx context.Context, d *schema.ResourceData, shared *sharedACLProvider, terraformVersion string) (interface{}, diag.Diagnostics) {
 	var diags diag.Diagnostics
-	p := &aclProvider{}
 
 	host := d.Get("host").(string)
 	token := d.Get("token").(string)
 
-	cli, err := acl.NewClient(ctx, host, token)
+	opts := acl.ClientOptions{
+		SkipCertVerification: d.Get("skip_cert_verification").(bool),
+		CACertFile:           d.Get("ca_cert_file").(string),
+		CACert:               d.Get("ca_cert").(string),
+		ClientCertFile:       d.Get("client_cert_file").(string),
+		ClientKeyFile:        d.Get("client_key_file").(string),
+		ClientCert:           d.Get("client_cert").(string),
+		ClientKey:            d.Get("client_key").(string),
+		Timeout:              time.Duration(d.Get("request_timeout").(int)) * time.Second,
+		Retry:                acl.DefaultRetryPolicy,
+	}
+
+	if list := d.Get("retry").([]interface{}); len(list) > 0 && list[0] != nil {
+		retry := list[0].(map[string]interface{})
+		opts.Retry.MaxAttempts = retry["max_attempts"].(int)
+		opts.Retry.MinBackoff, _ = time.ParseDuration(retry["min_backoff"].(string))
+		opts.Retry.MaxBackoff, _ = time.ParseDuration(retry["max_backoff"].(string))
+		if opts.Retry.MinBackoff > opts.Retry.MaxBackoff {
+			return nil, diag.Errorf("retry min_backoff (%s) must not be greater than max_backoff (%s)", opts.Retry.MinBackoff, opts.Retry.MaxBackoff)
+		}
+	}
+
+	defaultMetadata := map[string]string{}
+	for key, value := range d.Get("default_metadata").(map[string]interface{}) {
+		defaultMetadata[key] = value.(string)
+	}
+
+	p, err := shared.get(ctx, host, token, opts, defaultMetadata)
 	if err != nil {
 		return nil, diag.FromErr(err)
 	}
-
-	p.client = cli
 	return p, diags
 }
+
+// newACLProvider returns the configured provider shared by resources and data
+// sources, either implemented with the SDK or with the plugin framework.
+func newACLProvider(ctx context.Context, host, token string, opts acl.ClientOptions, defaultMetadata map[string]string) (*aclProvider, error) {
+	cli, err := acl.NewClient(ctx, host, token, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return &aclProvider{
+		client:          acl.NewCachedClient(cli),
+		defaultMetadata: defaultMetadata,
+	}, nil
+}
+
+// sharedACLProvider hands the same aclProvider to the SDK and the plugin
+// framework servers, which are configured separately, so a rule changed through
+// one of them is not read from a stale cache by the other.
+type sharedACLProvider struct {
+	mu              sync.Mutex
+	provider        *aclProvider
+	host            string
+	token           string
+	opts            acl.ClientOptions
+	defaultMetadata map[string]string
+}
+
+// get returns the provider built by a previous call with the same
+// configuration or builds a new one.
+func (s *sharedACLProvider) get(ctx context.Context, host, token string, opts acl.ClientOptions, defaultMetadata map[string]string) (*aclProvider, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.provider != nil && s.host == host && s.token == token && s.opts == opts && maps.Equal(s.defaultMetadata, defaultMetadata) {
+		return s.provider, nil
+	}
+
+	p, err := newACLProvider(ctx, host, token, opts, defaultMetadata)
+	if err != nil {
+		return nil, err
+	}
+
+	s.provider = p
+	s.host = host
+	s.token = token
+	s.opts = opts
+	s.defaultMetadata = defaultMetadata
+	return p, nil
+}
@@ -0,0 +1,180 @@
+// Copyright 2021 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-go/tfprotov6"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	echo "github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+	"github.com/tsuru/acl-api/api/types"
+	"github.com/tsuru/terraform-provider-acl/internal/acl"
+)
+
+func TestProvider(t *testing.T) {
+	require.NoError(t, Provider().InternalValidate())
+}
+
+func TestProviderServer(t *testing.T) {
+	providerServer, err := NewProviderServer(context.Background(), "test")
+	require.NoError(t, err)
+
+	resp, err := providerServer().GetProviderSchema(context.Background(), &tfprotov6.GetProviderSchemaRequest{})
+	require.NoError(t, err)
+	for _, diag := range resp.Diagnostics {
+		require.NotEqual(t, tfprotov6.DiagnosticSeverityError, diag.Severity, "%s: %s", diag.Summary, diag.Detail)
+	}
+
+	require.Contains(t, resp.ResourceSchemas, "acl_destination_rule")
+	require.Contains(t, resp.ResourceSchemas, "acl_destination_rule_set")
+	require.Contains(t, resp.DataSourceSchemas, "acl_destination_rule")
+	require.Contains(t, resp.DataSourceSchemas, "acl_destination_rules")
+}
+
+func TestSharedACLProvider(t *testing.T) {
+	ctx := context.Background()
+	shared := &sharedACLProvider{}
+
+	p1, err := shared.get(ctx, "http://localhost", "my-token", acl.ClientOptions{Retry: acl.DefaultRetryPolicy}, map[string]string{})
+	require.NoError(t, err)
+
+	p2, err := shared.get(ctx, "http://localhost", "my-token", acl.ClientOptions{Retry: acl.DefaultRetryPolicy}, nil)
+	require.NoError(t, err)
+	require.Same(t, p1, p2)
+
+	p3, err := shared.get(ctx, "http://localhost", "my-token", acl.ClientOptions{Retry: acl.DefaultRetryPolicy}, map[string]string{"managed_by": "terraform"})
+	require.NoError(t, err)
+	require.NotSame(t, p1, p3)
+	require.Equal(t, map[string]string{"managed_by": "terraform"}, p3.defaultMetadata)
+}
+
+func TestAccProviderSharedCache(t *testing.T) {
+	fakeServer := echo.New()
+	rules := []types.ServiceRule{}
+	nextID := 0
+
+	fakeServer.Any("/services/acl/proxy/:instance", func(c echo.Context) error {
+		callback := c.QueryParam("callback")
+		if strings.HasPrefix(callback, "/rule/") && c.Request().Method == http.MethodDelete {
+			ruleID := strings.TrimPrefix(callback, "/rule/")
+			for i, rule := range rules {
+				if rule.RuleID == ruleID {
+					rules = append(rules[:i], rules[i+1:]...)
+					return c.String(http.StatusOK, "")
+				}
+			}
+			return c.String(http.StatusNotFound, "")
+		}
+
+		if callback == "/rule" {
+			if c.Request().Method == http.MethodPost {
+				var rule types.ServiceRule
+				if err := c.Bind(&rule); err != nil {
+					return err
+				}
+				nextID++
+				rule.RuleID = fmt.Sprintf("my-rule-%d", nextID)
+				rules = append(rules, rule)
+				return c.JSON(http.StatusOK, rule)
+			}
+
+			return c.JSON(http.StatusOK, &acl.ServiceRuleData{
+				ServiceInstance: types.ServiceInstance{
+					BaseRules: rules,
+				},
+			})
+		}
+		t.Fatalf("method=%q, path=%q, callback=%q, err=\"Not found\"",
+			c.Request().Method,
+			c.Path(),
+			callback,
+		)
+		return c.String(http.StatusNotFound, "")
+	})
+
+	fakeServer.HTTPErrorHandler = func(err error, c echo.Context) {
+		t.Errorf("methods=%s, path=%s, err=%s", c.Request().Method, c.Path(), err.Error())
+	}
+	server := httptest.NewServer(fakeServer)
+	os.Setenv("TSURU_TARGET", server.URL)
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				// the rule set reads the instance before the rule is created
+				// and the data source reads it after, through the SDK server
+				Config: `
+resource "acl_destination_rule_set" "rules" {
+	instance = "my-acl"
+
+	rule {
+		dns = "example.org"
+	}
+}
+
+resource "acl_destination_rule" "rule" {
+	instance = "my-acl"
+	app      = "my-destination-app"
+
+	depends_on = [acl_destination_rule_set.rules]
+}
+
+data "acl_destination_rule" "rule" {
+	instance = "my-acl"
+	app      = "my-destination-app"
+
+	depends_on = [acl_destination_rule.rule]
+}
+				`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttrPair("data.acl_destination_rule.rule", "id", "acl_destination_rule.rule", "id"),
+					resource.TestCheckResourceAttr("data.acl_destination_rule.rule", "rule_id", "my-rule-2"),
+				),
+			},
+		},
+	})
+}
+
+func TestProviderDefaultMetadata(t *testing.T) {
+	p := &aclProvider{
+		defaultMetadata: map[string]string{
+			"managed_by": "terraform",
+			"repo":       "my-infra",
+		},
+	}
+
+	rule := &types.Rule{Metadata: map[string]string{"repo": "my-app"}}
+	p.applyDefaultMetadata(rule)
+	require.Equal(t, map[string]string{"managed_by": "terraform", "repo": "my-app"}, rule.Metadata)
+
+	require.Equal(t,
+		map[string]string{"repo": "my-app"},
+		resourceMetadata(map[string]interface{}{"repo": "my-app"}, rule.Metadata, p.defaultMetadata),
+	)
+	require.Equal(t,
+		map[string]string{"managed_by": "terraform", "repo": "my-app"},
+		resourceMetadata(map[string]interface{}{"managed_by": "terraform", "repo": "my-app"}, rule.Metadata, p.defaultMetadata),
+	)
+
+	// a rule created with an older value of a default key
+	require.Equal(t,
+		map[string]string{},
+		resourceMetadata(nil, map[string]string{"managed_by": "ansible", "repo": "my-infra"}, p.defaultMetadata),
+	)
+	require.Equal(t,
+		map[string]string{"owner": "my-team"},
+		resourceMetadata(map[string]interface{}{"owner": "my-team"}, map[string]string{"managed_by": "ansible", "owner": "my-team"}, p.defaultMetadata),
+	)
+}
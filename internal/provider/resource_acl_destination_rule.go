@@ -2,324 +2,966 @@ package provider
 
 import (
 	"context"
-	"errors"
 	"fmt"
-
-	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
-	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
-	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
-	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-framework-timeouts/resource/timeouts"
+	"github.com/hashicorp/terraform-plugin-framework-validators/int64validator"
+	"github.com/hashicorp/terraform-plugin-framework-validators/resourcevalidator"
+	"github.com/hashicorp/terraform-plugin-framework-validators/setvalidator"
+	"github.com/hashicorp/terraform-plugin-framework-validators/stringvalidator"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/booldefault"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/mapplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/objectplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringdefault"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	fwtypes "github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/retry"
 	"github.com/tsuru/acl-api/api/types"
 	"github.com/tsuru/terraform-provider-acl/internal/acl"
 )
 
-func resourceACLDestinationRule() *schema.Resource {
-	oneDestination := acl.Destinations
+const (
+	ruleCreateTimeout = 10 * time.Minute
+	ruleReadTimeout   = 5 * time.Minute
+	ruleUpdateTimeout = 10 * time.Minute
+	ruleDeleteTimeout = 10 * time.Minute
+)
 
-	return &schema.Resource{
-		CreateContext: resourceACLDestinationRuleCreate,
-		ReadContext:   resourceACLDestinationRuleRead,
-		DeleteContext: resourceACLDestinationRuleDelete,
-		Importer: &schema.ResourceImporter{
-			StateContext: resourceACLDestinationRuleImport,
-		},
+var (
+	_ resource.ResourceWithConfigure        = &destinationRuleResource{}
+	_ resource.ResourceWithConfigValidators = &destinationRuleResource{}
+	_ resource.ResourceWithValidateConfig   = &destinationRuleResource{}
+	_ resource.ResourceWithModifyPlan       = &destinationRuleResource{}
+	_ resource.ResourceWithImportState      = &destinationRuleResource{}
+	_ resource.ResourceWithUpgradeState     = &destinationRuleResource{}
+)
+
+type destinationRuleResource struct {
+	provider *aclProvider
+}
+
+type destinationRuleResourceModel struct {
+	ID                fwtypes.String          `tfsdk:"id"`
+	Instance          fwtypes.String          `tfsdk:"instance"`
+	ServiceName       fwtypes.String          `tfsdk:"service_name"`
+	IP                fwtypes.String          `tfsdk:"ip"`
+	DNS               fwtypes.String          `tfsdk:"dns"`
+	App               fwtypes.String          `tfsdk:"app"`
+	Pool              fwtypes.String          `tfsdk:"pool"`
+	Rpaas             *rpaasModel             `tfsdk:"rpaas"`
+	KubernetesService *kubernetesServiceModel `tfsdk:"kubernetes_service"`
+	Name              fwtypes.String          `tfsdk:"name"`
+	Metadata          fwtypes.Map             `tfsdk:"metadata"`
+	MetadataAll       fwtypes.Map             `tfsdk:"metadata_all"`
+	Creator           fwtypes.String          `tfsdk:"creator"`
+	CreatedAt         fwtypes.String          `tfsdk:"created_at"`
+	WaitForSync       fwtypes.Bool            `tfsdk:"wait_for_sync"`
+	Port              []portBlockModel        `tfsdk:"port"`
+	Timeouts          timeouts.Value          `tfsdk:"timeouts"`
+}
+
+type portBlockModel struct {
+	Protocol fwtypes.String `tfsdk:"protocol"`
+	Number   fwtypes.Int64  `tfsdk:"number"`
+	From     fwtypes.Int64  `tfsdk:"from"`
+	To       fwtypes.Int64  `tfsdk:"to"`
+}
+
+func newDestinationRuleResource() resource.Resource {
+	return &destinationRuleResource{}
+}
+
+func (r *destinationRuleResource) Metadata(ctx context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_destination_rule"
+}
+
+func (r *destinationRuleResource) Schema(ctx context.Context, req resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = destinationRuleResourceSchema(ctx)
+}
 
-		Schema: map[string]*schema.Schema{
-			"instance": {
-				Type:        schema.TypeString,
+func destinationRuleResourceSchema(ctx context.Context) schema.Schema {
+	portNumber := []validator.Int64{int64validator.Between(1, 65535)}
+
+	return schema.Schema{
+		Version: 1,
+		Attributes: map[string]schema.Attribute{
+			"id": schema.StringAttribute{
+				Computed:    true,
+				Description: "<SERVICE>::<INSTANCE>::<RULE_ID>",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"instance": schema.StringAttribute{
 				Required:    true,
-				ForceNew:    true,
 				Description: "ACL Instance Name",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
 			},
-			"service_name": {
-				Type:        schema.TypeString,
+			"service_name": schema.StringAttribute{
 				Optional:    true,
-				ForceNew:    true,
-				Default:     "acl",
+				Computed:    true,
+				Default:     stringdefault.StaticString("acl"),
 				Description: "ACL Service Name",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
 			},
 
-			"ip": {
-				Optional:     true,
-				ForceNew:     true,
-				Type:         schema.TypeString,
-				ExactlyOneOf: oneDestination,
-				ValidateFunc: validation.IsCIDR,
-				Description:  "Destination IP address",
+			"ip": schema.StringAttribute{
+				Optional:    true,
+				Description: "Destination IP address or CIDR, a bare IP is sent as /32 (or /128)",
+				Validators: []validator.String{
+					stringValidateFunc("value must be an IP or a CIDR", validateCIDR),
+				},
+				PlanModifiers: []planmodifier.String{
+					requiresReplaceIfNormalizedChanged(acl.NormalizeCIDR),
+				},
 			},
 
-			"dns": {
-				Optional:     true,
-				ForceNew:     true,
-				Type:         schema.TypeString,
-				ExactlyOneOf: oneDestination,
-				Description:  "Destination fully qualified domain name (FQDN)",
+			"dns": schema.StringAttribute{
+				Optional:    true,
+				Description: "Destination fully qualified domain name (FQDN), wildcard domains are written as *.example.org",
+				Validators: []validator.String{
+					stringValidateFunc("value must be a DNS name", validateDNS),
+				},
+				PlanModifiers: []planmodifier.String{
+					requiresReplaceIfNormalizedChanged(acl.NormalizeDNS),
+				},
 			},
 
-			"app": {
-				Optional:     true,
-				ForceNew:     true,
-				Type:         schema.TypeString,
-				ExactlyOneOf: oneDestination,
-				Description:  "Destination tsuru app name",
+			"app": schema.StringAttribute{
+				Optional:    true,
+				Description: "Destination tsuru app name",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
 			},
 
-			"pool": {
-				Optional:     true,
-				ForceNew:     true,
-				Type:         schema.TypeString,
-				ExactlyOneOf: oneDestination,
-				Description:  "Tsuru Pool name",
+			"pool": schema.StringAttribute{
+				Optional:    true,
+				Description: "Tsuru Pool name",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
 			},
 
-			"rpaas": {
-				Optional:     true,
-				ForceNew:     true,
-				Type:         schema.TypeList,
-				MaxItems:     1,
-				MinItems:     1,
-				ExactlyOneOf: oneDestination,
-				Elem:         rpaasSchema("rpaas"),
-				Description:  "Destination tsuru rpaas name",
+			"rpaas": schema.SingleNestedAttribute{
+				Optional:    true,
+				Description: "Destination tsuru rpaas name",
+				Attributes: map[string]schema.Attribute{
+					"service_name": schema.StringAttribute{
+						Required:    true,
+						Description: "Destination rpaas service name (ex: rpaasv2-be, rpaasv2-fe)",
+					},
+					"instance": schema.StringAttribute{
+						Required:    true,
+						Description: "Destination rpaas instance name",
+					},
+				},
+				PlanModifiers: []planmodifier.Object{
+					objectplanmodifier.RequiresReplace(),
+				},
+			},
+
+			"kubernetes_service": schema.SingleNestedAttribute{
+				Optional:    true,
+				Description: "Destination kubernetes service, deactivated in acl-api: only existing rules can be imported and managed, new ones are rejected",
+				Attributes: map[string]schema.Attribute{
+					"namespace": schema.StringAttribute{
+						Optional:    true,
+						Computed:    true,
+						Default:     stringdefault.StaticString("default"),
+						Description: "Destination kubernetes namespace",
+					},
+					"service_name": schema.StringAttribute{
+						Required:    true,
+						Description: "Destination kubernetes service name",
+					},
+					"cluster": schema.StringAttribute{
+						Optional:    true,
+						Description: "Destination kubernetes cluster name",
+					},
+				},
+				PlanModifiers: []planmodifier.Object{
+					objectplanmodifier.RequiresReplace(),
+				},
+			},
+
+			"name": schema.StringAttribute{
+				Optional:    true,
+				Computed:    true,
+				Description: "Rule name, shown in tsuru ACL listings",
+				Validators: []validator.String{
+					stringValidateFunc("value must be a DNS-1123 subdomain", validateRuleName),
+				},
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+					stringplanmodifier.RequiresReplace(),
+				},
 			},
 
-			"port": {
-				Optional:      true,
-				ForceNew:      true,
-				Type:          schema.TypeList,
-				Description:   "Destination port and protocol list",
-				ConflictsWith: []string{"app", "rpaas"},
-				Elem: &schema.Resource{
-					Schema: map[string]*schema.Schema{
-						"protocol": {
-							Type:         schema.TypeString,
-							Description:  "Procotol name (ex: TCP, UDP, tcp, udp...)",
-							Required:     true,
-							ForceNew:     true,
-							ValidateFunc: validation.StringInSlice([]string{"TCP", "UDP", "tcp", "udp"}, false),
+			"metadata": schema.MapAttribute{
+				Optional:    true,
+				ElementType: fwtypes.StringType,
+				Description: "Rule metadata (ex: owning repository, module path, ticket)",
+				PlanModifiers: []planmodifier.Map{
+					mapplanmodifier.RequiresReplaceIf(
+						func(ctx context.Context, req planmodifier.MapRequest, resp *mapplanmodifier.RequiresReplaceIfFuncResponse) {
+							// an empty map and no map are the same metadata
+							resp.RequiresReplace = len(req.PlanValue.Elements()) > 0 || len(req.StateValue.Elements()) > 0
 						},
-						"number": {
-							Type:         schema.TypeInt,
-							Description:  "Port number",
-							Required:     true,
-							ForceNew:     true,
-							ValidateFunc: validation.IsPortNumber,
+						"Changing the metadata replaces the rule.",
+						"Changing the metadata replaces the rule.",
+					),
+				},
+			},
+
+			"metadata_all": schema.MapAttribute{
+				Computed:    true,
+				ElementType: fwtypes.StringType,
+				Description: "Rule metadata, including the provider default_metadata",
+				PlanModifiers: []planmodifier.Map{
+					mapplanmodifier.UseStateForUnknown(),
+				},
+			},
+
+			"creator": schema.StringAttribute{
+				Computed:    true,
+				Description: "User who created the rule",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+
+			"created_at": schema.StringAttribute{
+				Computed:    true,
+				Description: "Rule creation time (RFC3339)",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+
+			"wait_for_sync": schema.BoolAttribute{
+				Optional:    true,
+				Computed:    true,
+				Default:     booldefault.StaticBool(false),
+				Description: "Wait until the rule is applied for every app bound to the instance before completing create or update, failing as soon as every pending app reports a failed sync",
+			},
+
+			"port": schema.SetNestedAttribute{
+				Optional:    true,
+				Description: "Destination port and protocol list, only supported for ip and dns destinations, each item sets a number or a from/to range and a rule has at most 1024 ports",
+				Validators: []validator.Set{
+					setvalidator.ConflictsWith(
+						path.MatchRoot("app"),
+						path.MatchRoot("pool"),
+						path.MatchRoot("rpaas"),
+						path.MatchRoot("kubernetes_service"),
+					),
+				},
+				NestedObject: schema.NestedAttributeObject{
+					Attributes: map[string]schema.Attribute{
+						"protocol": schema.StringAttribute{
+							Required:    true,
+							Description: "Procotol name (ex: TCP, UDP, tcp, udp...)",
+							Validators: []validator.String{
+								stringvalidator.OneOf("TCP", "UDP", "tcp", "udp"),
+							},
+						},
+						"number": schema.Int64Attribute{
+							Optional:    true,
+							Description: "Port number",
+							Validators:  portNumber,
+						},
+						"from": schema.Int64Attribute{
+							Optional:    true,
+							Description: "First port number of a range, must be set with to",
+							Validators:  portNumber,
+						},
+						"to": schema.Int64Attribute{
+							Optional:    true,
+							Description: "Last port number of a range, must be set with from",
+							Validators:  portNumber,
 						},
 					},
 				},
 			},
 		},
+		Blocks: map[string]schema.Block{
+			"timeouts": timeouts.Block(ctx, timeouts.Opts{
+				Create: true,
+				Read:   true,
+				Update: true,
+				Delete: true,
+			}),
+		},
 	}
 }
 
-func resourceACLDestinationRuleImport(ctx context.Context, rd *schema.ResourceData, m interface{}) ([]*schema.ResourceData, error) {
-	primaryID, err := acl.ParseResourceID(rd.Id())
+// requiresReplaceIfNormalizedChanged replaces the rule only when the new value
+// is a different destination, another spelling of the same destination is
+// updated in place without touching the rule.
+func requiresReplaceIfNormalizedChanged(normalize func(string) string) planmodifier.String {
+	return stringplanmodifier.RequiresReplaceIf(
+		func(ctx context.Context, req planmodifier.StringRequest, resp *stringplanmodifier.RequiresReplaceIfFuncResponse) {
+			resp.RequiresReplace = req.PlanValue.IsUnknown() || normalize(req.PlanValue.ValueString()) != normalize(req.StateValue.ValueString())
+		},
+		"Changing the destination replaces the rule.",
+		"Changing the destination replaces the rule.",
+	)
+}
+
+func (r *destinationRuleResource) Configure(ctx context.Context, req resource.ConfigureRequest, resp *resource.ConfigureResponse) {
+	if req.ProviderData == nil {
+		return
+	}
 
-	if err != nil {
-		return nil, err
+	provider, ok := req.ProviderData.(*aclProvider)
+	if !ok {
+		resp.Diagnostics.AddError("Unexpected provider data", fmt.Sprintf("expected *aclProvider, got %T", req.ProviderData))
+		return
+	}
+	r.provider = provider
+}
+
+func (r *destinationRuleResource) ConfigValidators(ctx context.Context) []resource.ConfigValidator {
+	destinations := make([]path.Expression, 0, len(acl.Destinations))
+	for _, destination := range acl.Destinations {
+		destinations = append(destinations, path.MatchRoot(destination))
+	}
+
+	return []resource.ConfigValidator{
+		resourcevalidator.ExactlyOneOf(destinations...),
+	}
+}
+
+func (r *destinationRuleResource) ValidateConfig(ctx context.Context, req resource.ValidateConfigRequest, resp *resource.ValidateConfigResponse) {
+	var ports fwtypes.Set
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("port"), &ports)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	blocks, _ := portBlocksFromSet(ports)
+	if err := validatePortBlocks(blocks, true); err != nil {
+		resp.Diagnostics.AddAttributeError(path.Root("port"), "Invalid port", err.Error())
+	}
+}
+
+// ModifyPlan rejects new kubernetes_service rules and, since a port change
+// creates a new rule, plans its computed attributes as unknown.
+func (r *destinationRuleResource) ModifyPlan(ctx context.Context, req resource.ModifyPlanRequest, resp *resource.ModifyPlanResponse) {
+	if req.Plan.Raw.IsNull() {
+		return
+	}
+
+	var plannedService, service fwtypes.Object
+	resp.Diagnostics.Append(req.Plan.GetAttribute(ctx, path.Root("kubernetes_service"), &plannedService)...)
+	if !req.State.Raw.IsNull() {
+		resp.Diagnostics.Append(req.State.GetAttribute(ctx, path.Root("kubernetes_service"), &service)...)
+	}
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	if !plannedService.IsNull() && (req.State.Raw.IsNull() || !plannedService.Equal(service)) {
+		resp.Diagnostics.AddAttributeError(path.Root("kubernetes_service"), "Deactivated destination", errKubernetesServiceDeactivated.Error())
+		return
 	}
 
-	cli := m.(*aclProvider).client
+	if req.State.Raw.IsNull() {
+		return
+	}
+
+	var plannedPorts, ports fwtypes.Set
+	resp.Diagnostics.Append(req.Plan.GetAttribute(ctx, path.Root("port"), &plannedPorts)...)
+	resp.Diagnostics.Append(req.State.GetAttribute(ctx, path.Root("port"), &ports)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	if !portsChanged(plannedPorts, ports) {
+		return
+	}
+
+	resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root("id"), fwtypes.StringUnknown())...)
+	resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root("creator"), fwtypes.StringUnknown())...)
+	resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root("created_at"), fwtypes.StringUnknown())...)
+	resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root("metadata_all"), fwtypes.MapUnknown(fwtypes.StringType))...)
+}
+
+// portsChanged tells whether the new ports are sent to acl-api as a
+// different port list, a port still unknown is taken as a change.
+func portsChanged(planned, current fwtypes.Set) bool {
+	plannedBlocks, known := portBlocksFromSet(planned)
+	if !known {
+		return true
+	}
+	currentBlocks, _ := portBlocksFromSet(current)
 
-	rules, err := cli.DestinationRules(ctx, primaryID.Service, primaryID.Instance)
+	return !acl.PortsEqual(expandProtoPorts(plannedBlocks), expandProtoPorts(currentBlocks))
+}
 
+func (r *destinationRuleResource) ImportState(ctx context.Context, req resource.ImportStateRequest, resp *resource.ImportStateResponse) {
+	primaryID, err := acl.ParseResourceID(req.ID)
 	if err != nil {
-		return nil, err
+		resp.Diagnostics.AddError("Invalid import ID", err.Error())
+		return
 	}
 
-	rule := acl.FindRuleByParsedPrimaryID(rules, primaryID)
-	if rule == nil {
-		return nil, errors.New("rule not found")
+	rules, err := r.provider.client.DestinationRules(ctx, primaryID.Service, primaryID.Instance)
+	if err != nil {
+		resp.Diagnostics.AddError("DestinationRules", err.Error())
+		return
 	}
 
-	rd.Set("service_name", primaryID.Service)
-	rd.Set("instance", primaryID.Instance)
-	rd.SetId(rule.RuleID)
+	rule, err := acl.FindSingleRuleByParsedPrimaryID(rules, primaryID)
+	if err != nil {
+		resp.Diagnostics.AddError("DestinationRules", err.Error())
+		return
+	}
 
-	return []*schema.ResourceData{rd}, nil
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("id"), ruleResourceID(primaryID.Service, primaryID.Instance, rule.RuleID))...)
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("service_name"), primaryID.Service)...)
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("instance"), primaryID.Instance)...)
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("wait_for_sync"), false)...)
 }
 
-func resourceACLDestinationRuleCreate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
-	// Warning or errors can be collected in a slice type
-	var diags diag.Diagnostics
-	cli := m.(*aclProvider).client
+func (r *destinationRuleResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	var plan destinationRuleResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
 
-	serviceName := d.Get("service_name").(string)
-	instance := d.Get("instance").(string)
-	rule := ruleFromResource(d)
+	timeout, diags := plan.Timeouts.Create(ctx, ruleCreateTimeout)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	cli := r.provider.client
+	serviceName := plan.ServiceName.ValueString()
+	instance := plan.Instance.ValueString()
+	attributes := plan.ruleAttributes()
+	if err := validatePortBlocks(attributes["port"].([]interface{}), true); err != nil {
+		resp.Diagnostics.AddAttributeError(path.Root("port"), "Invalid port", err.Error())
+		return
+	}
+	rule := ruleFromResource(attributes)
+	r.provider.applyDefaultMetadata(rule)
 
-	err := resource.RetryContext(ctx, d.Timeout(schema.TimeoutCreate), func() *resource.RetryError {
-		err := cli.DestinationRuleCreate(ctx, serviceName, instance, rule)
-		if err != nil {
-			if isRetryableError(err) {
-				return resource.RetryableError(err)
-			}
+	if err := createRuleWithRetry(ctx, cli, timeout, serviceName, instance, rule); err != nil {
+		resp.Diagnostics.AddError("DestinationRuleCreate", err.Error())
+		return
+	}
 
-			diags = append(diags, diag.Diagnostic{
-				Severity: diag.Error,
-				Summary:  "DestinationRuleCreate",
-				Detail:   err.Error(),
-			})
+	// the rule exists from here on, its ID is kept even if waiting for it
+	// fails, so the tainted rule is removed by the next apply
+	plan.ID = fwtypes.StringValue(ruleResourceID(serviceName, instance, rule.RuleID))
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("id"), plan.ID)...)
 
-			return resource.NonRetryableError(err)
+	if plan.WaitForSync.ValueBool() {
+		if err := waitForRuleSync(ctx, cli, serviceName, instance, rule.RuleID, timeout); err != nil {
+			resp.Diagnostics.AddError("DestinationRuleSync", err.Error())
+			return
 		}
+	}
 
-		d.SetId(rule.RuleID)
-		return nil
-	})
+	resp.Diagnostics.Append(r.readRule(ctx, &plan)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
+}
 
+func (r *destinationRuleResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	var state destinationRuleResourceModel
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	timeout, diags := state.Timeouts.Read(ctx, ruleReadTimeout)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	rule, err := r.findRule(ctx, state.ID.ValueString())
 	if err != nil {
-		return diag.FromErr(err)
+		resp.Diagnostics.AddError("DestinationRules", err.Error())
+		return
+	}
+
+	if rule == nil {
+		resp.State.RemoveResource(ctx)
+		return
 	}
 
-	return resourceACLDestinationRuleRead(ctx, d, m)
+	resp.Diagnostics.Append(r.flattenRule(ctx, &state, rule)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	resp.Diagnostics.Append(resp.State.Set(ctx, &state)...)
 }
 
-func resourceACLDestinationRuleRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
-	cli := m.(*aclProvider).client
+// Update replaces the rule in acl-api creating the new one before removing
+// the old one, so traffic is never interrupted. acl-api considers a rule
+// without ports equal to any rule with the same destination, so when ports
+// are added to such a rule the old one is removed first. Other changes, as
+// another spelling of the same destination, don't touch the rule.
+func (r *destinationRuleResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	var plan, state destinationRuleResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	timeout, diags := plan.Timeouts.Update(ctx, ruleUpdateTimeout)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	cli := r.provider.client
+	attributes := plan.ruleAttributes()
+	ports := attributes["port"].([]interface{})
+	if acl.PortsEqual(expandProtoPorts(ports), expandProtoPorts(state.ruleAttributes()["port"].([]interface{}))) {
+		plan.ID = state.ID
+		resp.Diagnostics.Append(r.readRule(ctx, &plan)...)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+		resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
+		return
+	}
 
-	rule, err := readRuleFromResourceData(ctx, cli, d)
+	serviceName, instance, _, err := parseRuleResourceID(state.ID.ValueString())
 	if err != nil {
-		return diag.FromErr(err)
+		resp.Diagnostics.AddError("Invalid ID", err.Error())
+		return
 	}
 
-	if rule == nil {
-		d.SetId("")
-		return nil
+	oldRule, err := r.findRule(ctx, state.ID.ValueString())
+	if err != nil {
+		resp.Diagnostics.AddError("DestinationRules", err.Error())
+		return
 	}
 
-	// Destination TsuruApp
-	if rule.Destination.TsuruApp == nil {
-		if err := d.Set("app", nil); err != nil {
-			return diag.FromErr(err)
-		}
-		if err := d.Set("pool", nil); err != nil {
-			return diag.FromErr(err)
-		}
-	} else {
-		if err := d.Set("app", rule.Destination.TsuruApp.AppName); err != nil {
-			return diag.FromErr(err)
+	if err = validatePortBlocks(ports, true); err != nil {
+		resp.Diagnostics.AddAttributeError(path.Root("port"), "Invalid port", err.Error())
+		return
+	}
+	rule := ruleFromResource(attributes)
+	r.provider.applyDefaultMetadata(rule)
+
+	err = createRuleWithRetry(ctx, cli, timeout, serviceName, instance, rule)
+	if acl.IsConflict(err) && oldRule != nil {
+		log.Printf("[DEBUG] rule %q conflicts with the new ports, removing it before creating the new rule", oldRule.RuleID)
+		if err = deleteRuleWithRetry(ctx, cli, timeout, serviceName, instance, oldRule.RuleID); err != nil {
+			resp.Diagnostics.AddError("DestinationRuleDelete", err.Error())
+			return
 		}
-		if err := d.Set("pool", rule.Destination.TsuruApp.PoolName); err != nil {
-			return diag.FromErr(err)
+		oldRule = nil
+
+		err = createRuleWithRetry(ctx, cli, timeout, serviceName, instance, rule)
+		if err != nil {
+			resp.State.RemoveResource(ctx)
 		}
 	}
+	if err != nil {
+		resp.Diagnostics.AddError("DestinationRuleCreate", err.Error())
+		return
+	}
 
-	// Destination IP
-	if rule.Destination.ExternalIP == nil {
-		if err := d.Set("ip", nil); err != nil {
-			return diag.FromErr(err)
-		}
-	} else {
-		if err := d.Set("ip", rule.Destination.ExternalIP.IP); err != nil {
-			return diag.FromErr(err)
-		}
-		if err := d.Set("port", flattenProtoPorts(rule.Destination.ExternalIP.Ports)); err != nil {
-			return diag.FromErr(err)
+	err = waitForRule(ctx, cli, serviceName, instance, rule.RuleID, timeout)
+	if err == nil && plan.WaitForSync.ValueBool() {
+		err = waitForRuleSync(ctx, cli, serviceName, instance, rule.RuleID, timeout)
+	}
+	if err != nil {
+		if deleteErr := cli.DestinationRuleDelete(ctx, rule.RuleID, serviceName, instance); deleteErr != nil {
+			err = fmt.Errorf("%w, also failed to remove new rule %q: %s", err, rule.RuleID, deleteErr.Error())
 		}
+		resp.Diagnostics.AddError("DestinationRuleSync", err.Error())
+		return
 	}
 
-	// Destination DNS
-	if rule.Destination.ExternalDNS == nil {
-		if err := d.Set("dns", nil); err != nil {
-			return diag.FromErr(err)
-		}
-	} else {
-		if err := d.Set("dns", rule.Destination.ExternalDNS.Name); err != nil {
-			return diag.FromErr(err)
-		}
-		if err := d.Set("port", flattenProtoPorts(rule.Destination.ExternalDNS.Ports)); err != nil {
-			return diag.FromErr(err)
+	plan.ID = fwtypes.StringValue(ruleResourceID(serviceName, instance, rule.RuleID))
+
+	if oldRule != nil {
+		err = deleteRuleWithRetry(ctx, cli, timeout, serviceName, instance, oldRule.RuleID)
+		if err != nil {
+			resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("id"), plan.ID)...)
+			resp.Diagnostics.AddError("DestinationRuleDelete", fmt.Sprintf("new rule %q was created but previous rule %q could not be removed: %s", rule.RuleID, oldRule.RuleID, err.Error()))
+			return
 		}
 	}
 
-	// Destination Rpaas Instance
-	if rule.Destination.RpaasInstance == nil {
-		if err := d.Set("rpaas", nil); err != nil {
-			return diag.FromErr(err)
-		}
-	} else {
-		if err := d.Set("rpaas", flattenRpaas(rule.Destination.RpaasInstance)); err != nil {
-			return diag.FromErr(err)
-		}
+	resp.Diagnostics.Append(r.readRule(ctx, &plan)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
+}
+
+func (r *destinationRuleResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	var state destinationRuleResourceModel
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
 	}
 
-	if rule.Destination.ExternalIP == nil && rule.Destination.ExternalDNS == nil {
-		if err := d.Set("port", nil); err != nil {
-			return diag.FromErr(err)
-		}
+	timeout, diags := state.Timeouts.Delete(ctx, ruleDeleteTimeout)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	rule, err := r.findRule(ctx, state.ID.ValueString())
+	if err != nil {
+		resp.Diagnostics.AddError("DestinationRules", err.Error())
+		return
+	}
+
+	if rule == nil {
+		return
+	}
+
+	serviceName, instance, _, err := parseRuleResourceID(state.ID.ValueString())
+	if err != nil {
+		resp.Diagnostics.AddError("Invalid ID", err.Error())
+		return
 	}
 
-	return nil
+	if err := deleteRuleWithRetry(ctx, r.provider.client, timeout, serviceName, instance, rule.RuleID); err != nil {
+		resp.Diagnostics.AddError("DestinationRuleDelete", err.Error())
+	}
 }
 
-func resourceACLDestinationRuleDelete(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
-	// Warning or errors can be collected in a slice type
+// findRule looks the rule of a resource ID up in acl-api, it returns nil when
+// the rule or its service instance is gone.
+func (r *destinationRuleResource) findRule(ctx context.Context, id string) (*types.Rule, error) {
+	serviceName, instance, ruleID, err := parseRuleResourceID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	rules, err := r.provider.client.DestinationRules(ctx, serviceName, instance)
+	if acl.IsNotFound(err) {
+		// the service instance is gone, so are its rules
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return acl.FindRuleBySingleID(rules, ruleID), nil
+}
+
+// readRule refreshes the model of a rule just created or updated.
+func (r *destinationRuleResource) readRule(ctx context.Context, model *destinationRuleResourceModel) diag.Diagnostics {
 	var diags diag.Diagnostics
-	cli := m.(*aclProvider).client
 
-	rule, err := readRuleFromResourceData(ctx, cli, d)
+	rule, err := r.findRule(ctx, model.ID.ValueString())
 	if err != nil {
-		return diag.FromErr(err)
+		diags.AddError("DestinationRules", err.Error())
+		return diags
 	}
 
 	if rule == nil {
-		return nil
+		diags.AddError("DestinationRules", fmt.Sprintf("rule %q not found", model.ID.ValueString()))
+		return diags
 	}
 
-	serviceName := d.Get("service_name").(string)
-	instance := d.Get("instance").(string)
+	return r.flattenRule(ctx, model, rule)
+}
 
-	err = resource.RetryContext(ctx, d.Timeout(schema.TimeoutCreate), func() *resource.RetryError {
-		err := cli.DestinationRuleDelete(ctx, rule.RuleID, serviceName, instance)
-		if err != nil {
-			if isRetryableError(err) {
-				return resource.RetryableError(err)
-			}
+// flattenRule stores the rule read from acl-api in the model. Only the
+// attribute of the rule destination type is set, the others are null. IP,
+// DNS and ports are kept as written while they mean the same destination.
+func (r *destinationRuleResource) flattenRule(ctx context.Context, model *destinationRuleResourceModel, rule *types.Rule) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	// IDs are rewritten to the canonical form
+	serviceName, instance, _, err := parseRuleResourceID(model.ID.ValueString())
+	if err != nil {
+		diags.AddError("Invalid ID", err.Error())
+		return diags
+	}
+	model.ID = fwtypes.StringValue(ruleResourceID(serviceName, instance, rule.RuleID))
+	model.ServiceName = fwtypes.StringValue(serviceName)
+	model.Instance = fwtypes.StringValue(instance)
+
+	model.Name = fwtypes.StringValue(rule.RuleName)
+	configuredMetadata := map[string]interface{}{}
+	for key, value := range model.Metadata.Elements() {
+		configuredMetadata[key] = value
+	}
+	metadata := resourceMetadata(configuredMetadata, rule.Metadata, r.provider.defaultMetadata)
+	if len(metadata) > 0 || !model.Metadata.IsNull() {
+		var d diag.Diagnostics
+		model.Metadata, d = fwtypes.MapValueFrom(ctx, fwtypes.StringType, metadata)
+		diags.Append(d...)
+	}
+	var d diag.Diagnostics
+	model.MetadataAll, d = fwtypes.MapValueFrom(ctx, fwtypes.StringType, rule.Metadata)
+	diags.Append(d...)
+	model.Creator = fwtypes.StringValue(rule.Creator)
+	model.CreatedAt = fwtypes.StringValue(flattenTime(rule.Created))
+	if model.WaitForSync.IsNull() {
+		model.WaitForSync = fwtypes.BoolValue(false)
+	}
+
+	current := *model
+	model.App = fwtypes.StringNull()
+	model.Pool = fwtypes.StringNull()
+	model.IP = fwtypes.StringNull()
+	model.DNS = fwtypes.StringNull()
+	model.Rpaas = nil
+	model.KubernetesService = nil
+	model.Port = nil
+
+	destination := rule.Destination
+	switch acl.RuleDestinationType(rule) {
+	case acl.DestinationApp:
+		model.App = fwtypes.StringValue(destination.TsuruApp.AppName)
+	case acl.DestinationPool:
+		model.Pool = fwtypes.StringValue(destination.TsuruApp.PoolName)
+	case acl.DestinationCIDR:
+		model.IP = equivalentString(current.IP, acl.NormalizeCIDR(destination.ExternalIP.IP), acl.NormalizeCIDR)
+		model.Port = portBlockModels(flattenPortBlocks(destination.ExternalIP.Ports, portBlocks(current.Port)))
+	case acl.DestinationDNS:
+		model.DNS = equivalentString(current.DNS, acl.NormalizeDNS(destination.ExternalDNS.Name), acl.NormalizeDNS)
+		model.Port = portBlockModels(flattenPortBlocks(destination.ExternalDNS.Ports, portBlocks(current.Port)))
+	case acl.DestinationRpaaS:
+		model.Rpaas = &rpaasModel{
+			ServiceName: fwtypes.StringValue(destination.RpaasInstance.ServiceName),
+			Instance:    fwtypes.StringValue(destination.RpaasInstance.Instance),
+		}
+	case acl.DestinationKubernetesService:
+		model.KubernetesService = &kubernetesServiceModel{
+			Namespace:   fwtypes.StringValue(acl.KubernetesNamespace(destination.KubernetesService.Namespace)),
+			ServiceName: fwtypes.StringValue(destination.KubernetesService.ServiceName),
+			Cluster:     fwtypes.StringNull(),
+		}
+		if destination.KubernetesService.ClusterName != "" {
+			model.KubernetesService.Cluster = fwtypes.StringValue(destination.KubernetesService.ClusterName)
+		}
+	}
+
+	return diags
+}
 
-			diags = append(diags, diag.Diagnostic{
-				Severity: diag.Error,
-				Summary:  "DestinationRuleDelete",
-				Detail:   err.Error(),
-			})
+// equivalentString keeps the current value while it normalizes to the value
+// read from acl-api, so the configured spelling isn't reported as a change.
+func equivalentString(current fwtypes.String, value string, normalize func(string) string) fwtypes.String {
+	if !current.IsNull() && !current.IsUnknown() && normalize(current.ValueString()) == value {
+		return current
+	}
+	return fwtypes.StringValue(value)
+}
+
+// ruleAttributes returns the model in the form read by ruleFromResource and
+// the port helpers shared with acl_destination_rule_set.
+func (m *destinationRuleResourceModel) ruleAttributes() ruleAttributes {
+	attributes := ruleAttributes{
+		"name": m.Name.ValueString(),
+		"app":  m.App.ValueString(),
+		"pool": m.Pool.ValueString(),
+		"ip":   m.IP.ValueString(),
+		"dns":  m.DNS.ValueString(),
+		"port": portBlocks(m.Port),
+	}
 
-			return resource.NonRetryableError(err)
+	metadata := map[string]interface{}{}
+	for key, value := range m.Metadata.Elements() {
+		if value, ok := value.(fwtypes.String); ok {
+			metadata[key] = value.ValueString()
 		}
+	}
+	attributes["metadata"] = metadata
 
-		d.SetId("")
-		return nil
-	})
+	if m.Rpaas != nil {
+		attributes["rpaas"] = []interface{}{map[string]interface{}{
+			"service_name": m.Rpaas.ServiceName.ValueString(),
+			"instance":     m.Rpaas.Instance.ValueString(),
+		}}
+	}
 
-	if err != nil {
-		return diag.FromErr(err)
+	if m.KubernetesService != nil {
+		attributes["kubernetes_service"] = []interface{}{map[string]interface{}{
+			"namespace":    m.KubernetesService.Namespace.ValueString(),
+			"service_name": m.KubernetesService.ServiceName.ValueString(),
+			"cluster":      m.KubernetesService.Cluster.ValueString(),
+		}}
 	}
 
-	return nil
+	return attributes
 }
 
-func readRuleFromResourceData(ctx context.Context, cli acl.Client, d *schema.ResourceData) (rule *types.Rule, err error) {
-	serviceName := d.Get("service_name").(string)
-	instance := d.Get("instance").(string)
+// portBlocks converts port items into the port blocks of the SDK resources,
+// null numbers are zero.
+func portBlocks(ports []portBlockModel) []interface{} {
+	blocks := make([]interface{}, 0, len(ports))
+	for _, port := range ports {
+		blocks = append(blocks, map[string]interface{}{
+			"protocol": port.Protocol.ValueString(),
+			"number":   int(port.Number.ValueInt64()),
+			"from":     int(port.From.ValueInt64()),
+			"to":       int(port.To.ValueInt64()),
+		})
+	}
+	return blocks
+}
 
-	parts := acl.ParseIDParts(d.Id())
-	fullID := d.Id()
-	id := fullID
+// portBlocksFromSet converts a port set which may hold unknown values, items
+// with an unknown value are left out since they can't be checked yet and
+// known is then false.
+func portBlocksFromSet(ports fwtypes.Set) (blocks []interface{}, known bool) {
+	known = !ports.IsUnknown()
+	for _, element := range ports.Elements() {
+		port, ok := element.(fwtypes.Object)
+		if !ok || port.IsUnknown() {
+			known = false
+			continue
+		}
+
+		block := map[string]interface{}{}
+		for name, value := range port.Attributes() {
+			if value.IsUnknown() {
+				block = nil
+				break
+			}
+			switch value := value.(type) {
+			case fwtypes.String:
+				block[name] = value.ValueString()
+			case fwtypes.Int64:
+				block[name] = int(value.ValueInt64())
+			}
+		}
+		if block == nil {
+			known = false
+			continue
+		}
+		blocks = append(blocks, block)
+	}
+	return blocks, known
+}
 
-	if len(parts) != 1 && len(parts) != 3 {
-		return nil, fmt.Errorf("invalid ID: %s", fullID)
+// portBlockModels converts port blocks back into port items, zero numbers are
+// null.
+func portBlockModels(blocks []interface{}) []portBlockModel {
+	var ports []portBlockModel
+	for _, block := range blocks {
+		portMap, ok := block.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		protocol, _ := portMap["protocol"].(string)
+		number, _ := portMap["number"].(int)
+		from, _ := portMap["from"].(int)
+		to, _ := portMap["to"].(int)
+		ports = append(ports, portBlockModel{
+			Protocol: fwtypes.StringValue(protocol),
+			Number:   int64OrNull(number),
+			From:     int64OrNull(from),
+			To:       int64OrNull(to),
+		})
 	}
+	return ports
+}
 
-	if len(parts) == 3 {
-		serviceName = parts[0]
-		instance = parts[1]
-		id = parts[2]
+func int64OrNull(n int) fwtypes.Int64 {
+	if n == 0 {
+		return fwtypes.Int64Null()
 	}
+	return fwtypes.Int64Value(int64(n))
+}
 
-	rules, err := cli.DestinationRules(ctx, serviceName, instance)
-	if err != nil {
-		return nil, err
+// ruleResourceID returns the ID of acl_destination_rule resources, in the
+// <SERVICE>::<INSTANCE>::<RULE_ID> format.
+func ruleResourceID(serviceName, instance, ruleID string) string {
+	return acl.GenerateID([]string{serviceName, instance, ruleID})
+}
+
+// parseRuleResourceID splits the resource ID, bare rule IDs written by older
+// versions are rewritten when the state is upgraded.
+func parseRuleResourceID(id string) (serviceName, instance, ruleID string, err error) {
+	parts := acl.ParseIDParts(id)
+	if len(parts) != 3 {
+		return "", "", "", fmt.Errorf("invalid ID %q, the format must be <SERVICE>::<INSTANCE>::<RULE_ID>", id)
 	}
 
-	rule = acl.FindRuleBySingleID(rules, id)
-	return rule, nil
+	return parts[0], parts[1], parts[2], nil
+}
+
+func waitForRule(ctx context.Context, cli acl.Client, serviceName, instance, ruleID string, timeout time.Duration) error {
+	return retry.RetryContext(ctx, timeout, func() *retry.RetryError {
+		rules, err := cli.DestinationRules(ctx, serviceName, instance)
+		if err != nil {
+			if isRetryableError(err) {
+				return retry.RetryableError(err)
+			}
+			return retry.NonRetryableError(err)
+		}
+
+		if acl.FindRuleBySingleID(rules, ruleID) == nil {
+			if cached, ok := cli.(*acl.CachedClient); ok {
+				cached.Invalidate(serviceName, instance)
+			}
+			return retry.RetryableError(fmt.Errorf("rule %q not found yet", ruleID))
+		}
+		return nil
+	})
+}
+
+// waitForRuleSync waits until acl-api sync workers report the rule as applied
+// for every app bound to the instance, failing as soon as every pending app
+// reports a failed sync.
+func waitForRuleSync(ctx context.Context, cli acl.Client, serviceName, instance, ruleID string, timeout time.Duration) error {
+	var status *acl.RuleSyncStatus
+	err := retry.RetryContext(ctx, timeout, func() *retry.RetryError {
+		var err error
+		status, err = cli.DestinationRuleSyncStatus(ctx, ruleID, serviceName, instance)
+		if err != nil {
+			if isRetryableError(err) {
+				return retry.RetryableError(err)
+			}
+			return retry.NonRetryableError(err)
+		}
+
+		if status.HasFailed() {
+			return retry.NonRetryableError(fmt.Errorf("rule %q failed to synchronize for %d of %d apps: %s", ruleID, status.Failed, status.Total, strings.Join(status.Errors, "; ")))
+		}
+		if !status.Done() {
+			return retry.RetryableError(fmt.Errorf("rule %q synchronized for %d of %d apps", ruleID, status.Synced, status.Total))
+		}
+		return nil
+	})
+
+	if err != nil && status != nil && !status.HasFailed() && len(status.Errors) > 0 {
+		return fmt.Errorf("%w, sync errors: %s", err, strings.Join(status.Errors, "; "))
+	}
+	return err
 }
@@ -0,0 +1,495 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+	"github.com/tsuru/acl-api/api/types"
+	"github.com/tsuru/terraform-provider-acl/internal/acl"
+)
+
+func resourceACLDestinationRuleSet() *schema.Resource {
+	return &schema.Resource{
+		CreateContext: resourceACLDestinationRuleSetCreate,
+		ReadContext:   resourceACLDestinationRuleSetRead,
+		UpdateContext: resourceACLDestinationRuleSetUpdate,
+		DeleteContext: resourceACLDestinationRuleSetDelete,
+		CustomizeDiff: resourceACLDestinationRuleSetCustomizeDiff,
+		Importer: &schema.ResourceImporter{
+			StateContext: resourceACLDestinationRuleSetImport,
+		},
+		Timeouts: &schema.ResourceTimeout{
+			Create: schema.DefaultTimeout(ruleCreateTimeout),
+			Update: schema.DefaultTimeout(ruleUpdateTimeout),
+			Delete: schema.DefaultTimeout(ruleDeleteTimeout),
+		},
+
+		Schema: map[string]*schema.Schema{
+			"instance": {
+				Type:        schema.TypeString,
+				Required:    true,
+				ForceNew:    true,
+				Description: "ACL Instance Name",
+			},
+			"service_name": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				ForceNew:    true,
+				Default:     "acl",
+				Description: "ACL Service Name",
+			},
+			"exclusive": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "Remove rules of the instance that are not declared in this resource",
+			},
+			"rule": {
+				Type:        schema.TypeSet,
+				Optional:    true,
+				Description: "Destination rules of the instance",
+				Set:         hashRuleSetItem,
+				Elem:        ruleSetItemSchema(),
+			},
+			"rule_ids": {
+				Type:        schema.TypeSet,
+				Computed:    true,
+				Description: "IDs of the rules managed by this resource",
+				Elem:        &schema.Schema{Type: schema.TypeString},
+			},
+		},
+	}
+}
+
+func ruleSetItemSchema() *schema.Resource {
+	return &schema.Resource{
+		Schema: map[string]*schema.Schema{
+			"ip": {
+				Optional:     true,
+				Type:         schema.TypeString,
+				ValidateFunc: validateCIDR,
+				StateFunc:    normalizeCIDRState,
+				Description:  "Destination IP address or CIDR, a bare IP is stored as /32 (or /128)",
+			},
+			"dns": {
+				Optional:     true,
+				Type:         schema.TypeString,
+				ValidateFunc: validateDNS,
+				StateFunc:    normalizeDNSState,
+				Description:  "Destination fully qualified domain name (FQDN), wildcard domains are written as *.example.org",
+			},
+			"app": {
+				Optional:    true,
+				Type:        schema.TypeString,
+				Description: "Destination tsuru app name",
+			},
+			"pool": {
+				Optional:    true,
+				Type:        schema.TypeString,
+				Description: "Tsuru Pool name",
+			},
+			"rpaas": {
+				Optional:    true,
+				Type:        schema.TypeList,
+				MaxItems:    1,
+				Description: "Destination tsuru rpaas name",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"service_name": {
+							Type:        schema.TypeString,
+							Description: "Destination rpaas service name (ex: rpaasv2-be, rpaasv2-fe)",
+							Required:    true,
+						},
+						"instance": {
+							Type:        schema.TypeString,
+							Description: "Destination rpaas instance name",
+							Required:    true,
+						},
+					},
+				},
+			},
+			"kubernetes_service": {
+				Optional:    true,
+				Type:        schema.TypeList,
+				MaxItems:    1,
+				Description: "Destination kubernetes service, deactivated in acl-api: only existing rules can be imported and managed, new ones are rejected",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"namespace": {
+							Type:        schema.TypeString,
+							Description: "Destination kubernetes namespace",
+							Optional:    true,
+							Default:     "default",
+						},
+						"service_name": {
+							Type:        schema.TypeString,
+							Description: "Destination kubernetes service name",
+							Required:    true,
+						},
+						"cluster": {
+							Type:        schema.TypeString,
+							Description: "Destination kubernetes cluster name",
+							Optional:    true,
+						},
+					},
+				},
+			},
+			"port": {
+				Optional:    true,
+				Type:        schema.TypeSet,
+				Description: "Destination port and protocol list, only supported for ip and dns destinations",
+				Set:         hashPortBlock,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"protocol": {
+							Type:             schema.TypeString,
+							Description:      "Procotol name (ex: TCP, UDP, tcp, udp...)",
+							Required:         true,
+							ValidateFunc:     validation.StringInSlice([]string{"TCP", "UDP", "tcp", "udp"}, false),
+							DiffSuppressFunc: suppressProtocolCase,
+						},
+						"number": {
+							Type:         schema.TypeInt,
+							Description:  "Port number",
+							Required:     true,
+							ValidateFunc: validation.IsPortNumber,
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func hashRuleSetItem(v interface{}) int {
+	return schema.HashString(acl.DestinationKey(ruleFromResource(ruleAttributes(v.(map[string]interface{})))))
+}
+
+func resourceACLDestinationRuleSetCustomizeDiff(ctx context.Context, d *schema.ResourceDiff, m interface{}) error {
+	oldItems, _ := d.GetChange("rule")
+	previous := map[string]bool{}
+	for _, item := range oldItems.(*schema.Set).List() {
+		previous[acl.DestinationKey(ruleFromResource(ruleAttributes(item.(map[string]interface{}))))] = true
+	}
+
+	for _, item := range d.Get("rule").(*schema.Set).List() {
+		attributes := ruleAttributes(item.(map[string]interface{}))
+		ports, _ := attributes.Get("port").(*schema.Set)
+		rule := ruleFromResource(attributes)
+		if err := validateRuleSetItem(rule, ports != nil && ports.Len() > 0); err != nil {
+			return err
+		}
+		if rule.Destination.KubernetesService != nil && !previous[acl.DestinationKey(rule)] {
+			return fmt.Errorf("rule %q: %s", acl.DestinationKey(rule), errKubernetesServiceDeactivated)
+		}
+	}
+
+	// created rules get new IDs and exclusive changes which rules are managed
+	if d.Id() != "" && (d.HasChange("rule") || d.HasChange("exclusive")) {
+		if err := d.SetNewComputed("rule_ids"); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func validateRuleSetItem(rule *types.Rule, hasPorts bool) error {
+	destination := rule.Destination
+	count := 0
+	for _, isSet := range []bool{
+		destination.TsuruApp != nil,
+		destination.RpaasInstance != nil,
+		destination.KubernetesService != nil,
+		destination.ExternalIP != nil,
+		destination.ExternalDNS != nil,
+	} {
+		if isSet {
+			count++
+		}
+	}
+
+	// an unknown destination (e.g. interpolated from another resource) is
+	// not validated until apply
+	if count == 0 {
+		return nil
+	}
+
+	if count > 1 {
+		return fmt.Errorf("rule %q: exactly one of %s must be set", acl.DestinationKey(rule), strings.Join(acl.Destinations, ", "))
+	}
+
+	if hasPorts && destination.ExternalIP == nil && destination.ExternalDNS == nil {
+		return fmt.Errorf("rule %q: %s", acl.DestinationKey(rule), errPortsNotSupported)
+	}
+
+	return nil
+}
+
+func resourceACLDestinationRuleSetImport(ctx context.Context, d *schema.ResourceData, m interface{}) ([]*schema.ResourceData, error) {
+	parts := acl.ParseIDParts(d.Id())
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("invalid ID %q, the format must be <SERVICE>::<INSTANCE>", d.Id())
+	}
+
+	cli := m.(*aclProvider).client
+	rules, err := cli.DestinationRules(ctx, parts[0], parts[1])
+	if err != nil {
+		return nil, err
+	}
+	rules = acl.ActiveRules(rules)
+
+	d.Set("service_name", parts[0])
+	d.Set("instance", parts[1])
+	if err := d.Set("rule", flattenRuleSet(rules)); err != nil {
+		return nil, err
+	}
+
+	return []*schema.ResourceData{d}, nil
+}
+
+func resourceACLDestinationRuleSetCreate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	serviceName := d.Get("service_name").(string)
+	instance := d.Get("instance").(string)
+
+	d.SetId(acl.GenerateID([]string{serviceName, instance}))
+
+	diags := applyRuleSet(ctx, d, m, nil, d.Timeout(schema.TimeoutCreate))
+	if diags.HasError() {
+		return diags
+	}
+
+	return append(diags, resourceACLDestinationRuleSetRead(ctx, d, m)...)
+}
+
+func resourceACLDestinationRuleSetUpdate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	oldRules, _ := d.GetChange("rule")
+
+	diags := applyRuleSet(ctx, d, m, oldRules.(*schema.Set).List(), d.Timeout(schema.TimeoutUpdate))
+	if diags.HasError() {
+		return diags
+	}
+
+	return append(diags, resourceACLDestinationRuleSetRead(ctx, d, m)...)
+}
+
+// applyRuleSet creates the declared rules missing in acl-api and removes the
+// previously declared ones that are gone from config, or, in exclusive mode,
+// every rule of the instance not declared.
+func applyRuleSet(ctx context.Context, d *schema.ResourceData, m interface{}, oldItems []interface{}, timeout time.Duration) diag.Diagnostics {
+	cli := m.(*aclProvider).client
+
+	serviceName := d.Get("service_name").(string)
+	instance := d.Get("instance").(string)
+	exclusive := d.Get("exclusive").(bool)
+
+	current, err := cli.DestinationRules(ctx, serviceName, instance)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	current = acl.ActiveRules(current)
+
+	desired := map[string]*types.Rule{}
+	for _, item := range d.Get("rule").(*schema.Set).List() {
+		rule := ruleFromResource(ruleAttributes(item.(map[string]interface{})))
+		m.(*aclProvider).applyDefaultMetadata(rule)
+		desired[acl.DestinationKey(rule)] = rule
+	}
+
+	previous := map[string]bool{}
+	for _, item := range oldItems {
+		previous[acl.DestinationKey(ruleFromResource(ruleAttributes(item.(map[string]interface{}))))] = true
+	}
+
+	existing := map[string]bool{}
+	var toDelete []types.Rule
+	for _, rule := range current {
+		key := acl.DestinationKey(&rule)
+		existing[key] = true
+
+		if desired[key] != nil {
+			continue
+		}
+		if exclusive || previous[key] {
+			toDelete = append(toDelete, rule)
+		}
+	}
+
+	var diags diag.Diagnostics
+	for key, rule := range desired {
+		if existing[key] {
+			continue
+		}
+		err := createRuleWithRetry(ctx, cli, timeout, serviceName, instance, rule)
+		if i := conflictingRule(toDelete, rule); acl.IsConflict(err) && i >= 0 {
+			oldRule := toDelete[i]
+			toDelete = append(toDelete[:i], toDelete[i+1:]...)
+
+			log.Printf("[DEBUG] rule %q conflicts with rule %q, removing it before creating the new rule", key, oldRule.RuleID)
+			if err = deleteRuleWithRetry(ctx, cli, timeout, serviceName, instance, oldRule.RuleID); err != nil {
+				diags = append(diags, diag.Diagnostic{
+					Severity: diag.Error,
+					Summary:  "DestinationRuleDelete",
+					Detail:   fmt.Sprintf("rule %q: %s", oldRule.RuleID, err.Error()),
+				})
+				continue
+			}
+			err = createRuleWithRetry(ctx, cli, timeout, serviceName, instance, rule)
+		}
+		if err != nil {
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Error,
+				Summary:  "DestinationRuleCreate",
+				Detail:   fmt.Sprintf("rule %q: %s", key, err.Error()),
+			})
+		}
+	}
+
+	for _, rule := range toDelete {
+		if err := deleteRuleWithRetry(ctx, cli, timeout, serviceName, instance, rule.RuleID); err != nil {
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Error,
+				Summary:  "DestinationRuleDelete",
+				Detail:   fmt.Sprintf("rule %q: %s", rule.RuleID, err.Error()),
+			})
+		}
+	}
+
+	return diags
+}
+
+// conflictingRule returns the index of the rule to delete that acl-api takes
+// as the same rule as the new one (e.g. the portless rule of a host getting
+// ports), or -1 when there is none.
+func conflictingRule(rules []types.Rule, rule *types.Rule) int {
+	for i := range rules {
+		if acl.RulesConflict(&rules[i], rule) {
+			return i
+		}
+	}
+	return -1
+}
+
+func resourceACLDestinationRuleSetRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	cli := m.(*aclProvider).client
+
+	serviceName := d.Get("service_name").(string)
+	instance := d.Get("instance").(string)
+
+	current, err := cli.DestinationRules(ctx, serviceName, instance)
+	if acl.IsNotFound(err) {
+		d.SetId("")
+		return nil
+	}
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	current = acl.ActiveRules(current)
+
+	managed := current
+	if !d.Get("exclusive").(bool) {
+		declared := map[string]bool{}
+		for _, item := range d.Get("rule").(*schema.Set).List() {
+			declared[acl.DestinationKey(ruleFromResource(ruleAttributes(item.(map[string]interface{}))))] = true
+		}
+
+		managed = nil
+		for _, rule := range current {
+			if declared[acl.DestinationKey(&rule)] {
+				managed = append(managed, rule)
+			}
+		}
+	}
+
+	if err := d.Set("rule", flattenRuleSet(managed)); err != nil {
+		return diag.FromErr(err)
+	}
+
+	var ruleIDs []interface{}
+	for _, rule := range managed {
+		ruleIDs = append(ruleIDs, rule.RuleID)
+	}
+	if err := d.Set("rule_ids", ruleIDs); err != nil {
+		return diag.FromErr(err)
+	}
+
+	return nil
+}
+
+func resourceACLDestinationRuleSetDelete(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	cli := m.(*aclProvider).client
+
+	serviceName := d.Get("service_name").(string)
+	instance := d.Get("instance").(string)
+
+	current, err := cli.DestinationRules(ctx, serviceName, instance)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	current = acl.ActiveRules(current)
+
+	declared := map[string]bool{}
+	for _, item := range d.Get("rule").(*schema.Set).List() {
+		declared[acl.DestinationKey(ruleFromResource(ruleAttributes(item.(map[string]interface{}))))] = true
+	}
+
+	var diags diag.Diagnostics
+	for _, rule := range current {
+		if !declared[acl.DestinationKey(&rule)] {
+			continue
+		}
+		if err := deleteRuleWithRetry(ctx, cli, d.Timeout(schema.TimeoutDelete), serviceName, instance, rule.RuleID); err != nil {
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Error,
+				Summary:  "DestinationRuleDelete",
+				Detail:   fmt.Sprintf("rule %q: %s", rule.RuleID, err.Error()),
+			})
+		}
+	}
+
+	if !diags.HasError() {
+		d.SetId("")
+	}
+	return diags
+}
+
+func flattenRuleSet(rules []types.Rule) []interface{} {
+	items := make([]interface{}, 0, len(rules))
+	for i := range rules {
+		items = append(items, flattenRuleDestination(&rules[i]))
+	}
+	return items
+}
+
+func createRuleWithRetry(ctx context.Context, cli acl.Client, timeout time.Duration, serviceName, instance string, rule *types.Rule) error {
+	return resource.RetryContext(ctx, timeout, func() *resource.RetryError {
+		err := cli.DestinationRuleCreate(ctx, serviceName, instance, rule)
+		if err != nil {
+			if isRetryableError(err) {
+				return resource.RetryableError(err)
+			}
+			return resource.NonRetryableError(err)
+		}
+		return nil
+	})
+}
+
+func deleteRuleWithRetry(ctx context.Context, cli acl.Client, timeout time.Duration, serviceName, instance, ruleID string) error {
+	return resource.RetryContext(ctx, timeout, func() *resource.RetryError {
+		err := cli.DestinationRuleDelete(ctx, ruleID, serviceName, instance)
+		if err != nil && !acl.IsNotFound(err) {
+			if isRetryableError(err) {
+				return resource.RetryableError(err)
+			}
+			return resource.NonRetryableError(err)
+		}
+		return nil
+	})
+}
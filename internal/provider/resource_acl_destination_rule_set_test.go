@@ -0,0 +1,283 @@
+// Copyright 2021 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package provider
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+	echo "github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+	"github.com/tsuru/acl-api/api/types"
+	"github.com/tsuru/terraform-provider-acl/internal/acl"
+)
+
+func TestAccResourceDestinationRuleSet(t *testing.T) {
+	fakeServer := echo.New()
+	rules := []types.ServiceRule{
+		{
+			Rule: types.Rule{
+				RuleID: "click-ops-rule",
+				Destination: types.RuleType{
+					TsuruApp: &types.TsuruAppRule{
+						AppName: "click-ops-app",
+					},
+				},
+			},
+		},
+	}
+	nextID := 0
+
+	fakeServer.Any("/services/acl/proxy/:instance", func(c echo.Context) error {
+		callback := c.QueryParam("callback")
+		if strings.HasPrefix(callback, "/rule/") && c.Request().Method == http.MethodDelete {
+			ruleID := strings.TrimPrefix(callback, "/rule/")
+			for i, rule := range rules {
+				if rule.RuleID == ruleID {
+					rules = append(rules[:i], rules[i+1:]...)
+					return c.String(http.StatusOK, "")
+				}
+			}
+			return c.String(http.StatusNotFound, "")
+		}
+
+		if callback == "/rule" {
+			if c.Request().Method == http.MethodPost {
+				var rule types.ServiceRule
+				if err := c.Bind(&rule); err != nil {
+					return err
+				}
+				nextID++
+				rule.RuleID = fmt.Sprintf("my-rule-%d", nextID)
+				rules = append(rules, rule)
+				return c.JSON(http.StatusOK, rule)
+			}
+
+			return c.JSON(http.StatusOK, &acl.ServiceRuleData{
+				ServiceInstance: types.ServiceInstance{
+					BaseRules: rules,
+				},
+			})
+		}
+		t.Fatalf("method=%q, path=%q, callback=%q, err=\"Not found\"",
+			c.Request().Method,
+			c.Path(),
+			callback,
+		)
+		return c.String(http.StatusNotFound, "")
+	})
+
+	fakeServer.HTTPErrorHandler = func(err error, c echo.Context) {
+		t.Errorf("methods=%s, path=%s, err=%s", c.Request().Method, c.Path(), err.Error())
+	}
+	server := httptest.NewServer(fakeServer)
+	os.Setenv("TSURU_TARGET", server.URL)
+
+	resourceName := "acl_destination_rule_set.rules"
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		CheckDestroy:             nil,
+		Steps: []resource.TestStep{
+			{
+				Config: `
+resource "acl_destination_rule_set" "rules" {
+	instance = "my-acl"
+
+	rule {
+		app = "my-destination-app"
+	}
+
+	rule {
+		dns = "example.org"
+
+		port {
+			number   = 443
+			protocol = "TCP"
+		}
+	}
+}
+				`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					testAccResourceExists(resourceName),
+					resource.TestCheckResourceAttr(resourceName, "id", "acl::my-acl"),
+					resource.TestCheckResourceAttr(resourceName, "rule.#", "2"),
+					resource.TestCheckResourceAttr(resourceName, "rule_ids.#", "2"),
+					testAccRuleSetRemoteRules(&rules, 3),
+				),
+			},
+			{
+				Config: `
+resource "acl_destination_rule_set" "rules" {
+	instance  = "my-acl"
+	exclusive = true
+
+	rule {
+		app = "my-destination-app"
+	}
+}
+				`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr(resourceName, "rule.#", "1"),
+					resource.TestCheckTypeSetElemNestedAttrs(resourceName, "rule.*", map[string]string{
+						"app": "my-destination-app",
+					}),
+					resource.TestCheckResourceAttr(resourceName, "rule_ids.#", "1"),
+					func(s *terraform.State) error {
+						// rules are created in no particular order
+						if len(rules) != 1 {
+							return fmt.Errorf("expected 1 rule in acl-api, got %d", len(rules))
+						}
+						return resource.TestCheckTypeSetElemAttr(resourceName, "rule_ids.*", rules[0].RuleID)(s)
+					},
+					testAccRuleSetRemoteRules(&rules, 1),
+				),
+			},
+		},
+	})
+}
+
+func TestAccResourceDestinationRuleSetAddPorts(t *testing.T) {
+	fakeServer := echo.New()
+	var rules []types.ServiceRule
+	nextID := 0
+
+	fakeServer.Any("/services/acl/proxy/:instance", func(c echo.Context) error {
+		callback := c.QueryParam("callback")
+		if strings.HasPrefix(callback, "/rule/") && c.Request().Method == http.MethodDelete {
+			ruleID := strings.TrimPrefix(callback, "/rule/")
+			for i, rule := range rules {
+				if rule.RuleID == ruleID {
+					rules = append(rules[:i], rules[i+1:]...)
+					return c.String(http.StatusOK, "")
+				}
+			}
+			return c.String(http.StatusNotFound, "")
+		}
+
+		if callback == "/rule" {
+			if c.Request().Method == http.MethodPost {
+				var rule types.ServiceRule
+				if err := c.Bind(&rule); err != nil {
+					return err
+				}
+				// acl-api takes a portless rule as the same rule as any
+				// other rule for its host
+				for _, existing := range rules {
+					if existing.Destination.ExternalDNS.Name == rule.Destination.ExternalDNS.Name &&
+						(existing.Destination.ExternalDNS.Ports == nil || rule.Destination.ExternalDNS.Ports == nil) {
+						return c.JSON(http.StatusConflict, map[string]string{"message": "rule already exists"})
+					}
+				}
+				nextID++
+				rule.RuleID = fmt.Sprintf("my-rule-%d", nextID)
+				rules = append(rules, rule)
+				return c.JSON(http.StatusOK, rule)
+			}
+
+			return c.JSON(http.StatusOK, &acl.ServiceRuleData{
+				ServiceInstance: types.ServiceInstance{
+					BaseRules: rules,
+				},
+			})
+		}
+		t.Fatalf("method=%q, path=%q, callback=%q, err=\"Not found\"",
+			c.Request().Method,
+			c.Path(),
+			callback,
+		)
+		return c.String(http.StatusNotFound, "")
+	})
+
+	fakeServer.HTTPErrorHandler = func(err error, c echo.Context) {
+		t.Errorf("methods=%s, path=%s, err=%s", c.Request().Method, c.Path(), err.Error())
+	}
+	server := httptest.NewServer(fakeServer)
+	os.Setenv("TSURU_TARGET", server.URL)
+
+	resourceName := "acl_destination_rule_set.rules"
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		CheckDestroy:             nil,
+		Steps: []resource.TestStep{
+			{
+				Config: `
+resource "acl_destination_rule_set" "rules" {
+	instance = "my-acl"
+
+	rule {
+		dns = "example.org"
+	}
+}
+				`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					testAccResourceExists(resourceName),
+					resource.TestCheckTypeSetElemAttr(resourceName, "rule_ids.*", "my-rule-1"),
+					testAccRuleSetRemoteRules(&rules, 1),
+				),
+			},
+			{
+				Config: `
+resource "acl_destination_rule_set" "rules" {
+	instance = "my-acl"
+
+	rule {
+		dns = "example.org"
+
+		port {
+			number   = 443
+			protocol = "TCP"
+		}
+	}
+}
+				`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr(resourceName, "rule_ids.#", "1"),
+					resource.TestCheckTypeSetElemAttr(resourceName, "rule_ids.*", "my-rule-2"),
+					resource.TestCheckTypeSetElemNestedAttrs(resourceName, "rule.*.port.*", map[string]string{"protocol": "TCP", "number": "443"}),
+					testAccRuleSetRemoteRules(&rules, 1),
+				),
+			},
+		},
+	})
+}
+
+func testAccRuleSetRemoteRules(rules *[]types.ServiceRule, expected int) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		if len(*rules) != expected {
+			return fmt.Errorf("expected %d rules in acl-api, got %d", expected, len(*rules))
+		}
+		return nil
+	}
+}
+
+func TestValidateRuleSetItem(t *testing.T) {
+	app := &types.Rule{Destination: types.RuleType{TsuruApp: &types.TsuruAppRule{AppName: "my-app"}}}
+	dns := &types.Rule{Destination: types.RuleType{ExternalDNS: &types.ExternalDNSRule{Name: "example.org"}}}
+
+	require.NoError(t, validateRuleSetItem(app, false))
+	require.NoError(t, validateRuleSetItem(dns, true))
+	require.NoError(t, validateRuleSetItem(&types.Rule{}, true))
+	require.EqualError(t, validateRuleSetItem(app, true), `rule "app::my-app": `+errPortsNotSupported.Error())
+
+	pool := &types.Rule{Destination: types.RuleType{TsuruApp: &types.TsuruAppRule{PoolName: "my-pool"}}}
+	require.NoError(t, validateRuleSetItem(pool, false))
+	require.EqualError(t, validateRuleSetItem(pool, true), `rule "pool::my-pool": `+errPortsNotSupported.Error())
+}
+
+func TestResourceDestinationRuleSetTimeouts(t *testing.T) {
+	timeouts := resourceACLDestinationRuleSet().Timeouts
+	require.NotNil(t, timeouts)
+	require.Equal(t, ruleCreateTimeout, *timeouts.Create)
+	require.Equal(t, ruleUpdateTimeout, *timeouts.Update)
+	require.Equal(t, ruleDeleteTimeout, *timeouts.Delete)
+}
@@ -5,33 +5,36 @@
 package provider
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"regexp"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/tsuru/terraform-provider-acl/internal/acl"
 
+	"github.com/hashicorp/terraform-plugin-go/tfprotov6"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
-	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
 	echo "github.com/labstack/echo/v4"
 	"github.com/stretchr/testify/require"
 	"github.com/tsuru/acl-api/api/types"
 )
 
-var testAccProvider *schema.Provider
-var testAccProviderFactories = map[string]func() (*schema.Provider, error){
-	"acl": func() (*schema.Provider, error) {
-		return testAccProvider, nil
+var testAccProtoV6ProviderFactories = map[string]func() (tfprotov6.ProviderServer, error){
+	"acl": func() (tfprotov6.ProviderServer, error) {
+		providerServer, err := NewProviderServer(context.Background(), "test")
+		if err != nil {
+			return nil, err
+		}
+		return providerServer(), nil
 	},
 }
 
-func init() {
-	testAccProvider = Provider()
-}
-
 func TestAccResourceDestinationRuleApp(t *testing.T) {
 	fakeServer := echo.New()
 	myRule := types.ServiceRule{
@@ -80,9 +83,9 @@ func TestAccResourceDestinationRuleApp(t *testing.T) {
 
 	resourceName := "acl_destination_rule.rule"
 	resource.Test(t, resource.TestCase{
-		PreCheck:          func() { testAccPreCheck(t) },
-		ProviderFactories: testAccProviderFactories,
-		CheckDestroy:      nil,
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		CheckDestroy:             nil,
 		Steps: []resource.TestStep{
 			{
 				Config: `
@@ -155,9 +158,9 @@ func TestAccImportRuleApp(t *testing.T) {
 					`
 
 	resource.Test(t, resource.TestCase{
-		PreCheck:          func() { testAccPreCheck(t) },
-		ProviderFactories: testAccProviderFactories,
-		CheckDestroy:      nil,
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		CheckDestroy:             nil,
 		Steps: []resource.TestStep{
 			{
 				Config:        config,
@@ -178,8 +181,111 @@ func TestAccImportRuleApp(t *testing.T) {
 	})
 }
 
+func TestAccImportRulePool(t *testing.T) {
+	fakeServer := echo.New()
+	appRule := types.ServiceRule{
+		Rule: types.Rule{
+			RuleID: "my-app-rule",
+			Destination: types.RuleType{
+				TsuruApp: &types.TsuruAppRule{
+					AppName:  "my-destination-app",
+					PoolName: "my-pool",
+				},
+			},
+		},
+	}
+	poolRule := types.ServiceRule{
+		Rule: types.Rule{
+			RuleID: "my-pool-rule",
+			Destination: types.RuleType{
+				TsuruApp: &types.TsuruAppRule{
+					PoolName: "my-pool",
+				},
+			},
+		},
+	}
+
+	fakeServer.Any("/services/acl/proxy/:instance", func(c echo.Context) error {
+		callback := c.QueryParam("callback")
+		if callback == "/rule" && c.Request().Method == http.MethodGet {
+			return c.JSON(http.StatusOK, &acl.ServiceRuleData{
+				ServiceInstance: types.ServiceInstance{
+					BaseRules: []types.ServiceRule{
+						appRule,
+						poolRule,
+					},
+				},
+			})
+		}
+		t.Fatalf("method=%q, path=%q, callback=%q, err=\"Not found\"",
+			c.Request().Method,
+			c.Path(),
+			callback,
+		)
+		return c.String(http.StatusNotFound, "")
+	})
+
+	fakeServer.HTTPErrorHandler = func(err error, c echo.Context) {
+		t.Errorf("methods=%s, path=%s, err=%s", c.Request().Method, c.Path(), err.Error())
+	}
+	server := httptest.NewServer(fakeServer)
+	os.Setenv("TSURU_TARGET", server.URL)
+
+	importedRule := func(id, attribute, value, empty string) resource.ImportStateCheckFunc {
+		return func(states []*terraform.InstanceState) error {
+			if len(states) != 1 {
+				return fmt.Errorf("expected 1 imported state, got %d", len(states))
+			}
+			state := states[0]
+			if state.ID != id {
+				return fmt.Errorf("expected rule %q, got %q", id, state.ID)
+			}
+			if state.Attributes[attribute] != value {
+				return fmt.Errorf("expected %s to be %q, got %q", attribute, value, state.Attributes[attribute])
+			}
+			if state.Attributes[empty] != "" {
+				return fmt.Errorf("expected %s to be empty, got %q", empty, state.Attributes[empty])
+			}
+			return nil
+		}
+	}
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		CheckDestroy:             nil,
+		Steps: []resource.TestStep{
+			{
+				Config: `
+	resource "acl_destination_rule" "rule" {
+		instance =  "my-acl"
+		pool = "my-pool"
+	}
+					`,
+				ImportState:      true,
+				ImportStateId:    "acl-rule::acl::my-acl::pool::my-pool",
+				ResourceName:     "acl_destination_rule.rule",
+				ImportStateCheck: importedRule("acl::my-acl::my-pool-rule", "pool", "my-pool", "app"),
+			},
+			{
+				Config: `
+	resource "acl_destination_rule" "rule" {
+		instance =  "my-acl"
+		app = "my-destination-app"
+	}
+					`,
+				ImportState:      true,
+				ImportStateId:    "acl-rule::acl::my-acl::app::my-destination-app",
+				ResourceName:     "acl_destination_rule.rule",
+				ImportStateCheck: importedRule("acl::my-acl::my-app-rule", "app", "my-destination-app", "pool"),
+			},
+		},
+	})
+}
+
 func TestAccResourceDestinationRuleDNS(t *testing.T) {
 	fakeServer := echo.New()
+	creates := 0
 	myRule := types.ServiceRule{
 		Rule: types.Rule{
 			RuleID: "my-rule",
@@ -209,6 +315,7 @@ func TestAccResourceDestinationRuleDNS(t *testing.T) {
 
 		if callback == "/rule" {
 			if c.Request().Method == http.MethodPost {
+				creates++
 				return c.JSON(http.StatusOK, myRule)
 			}
 
@@ -234,11 +341,31 @@ func TestAccResourceDestinationRuleDNS(t *testing.T) {
 	server := httptest.NewServer(fakeServer)
 	os.Setenv("TSURU_TARGET", server.URL)
 
+	// an equivalent spelling is kept in state without recreating the rule
+	equivalentConfig := `
+resource "acl_destination_rule" "rule" {
+	instance =  "my-acl"
+
+	dns = "Example.org."
+
+	port = [
+		{
+			number   = 80
+			protocol = "TCP"
+		},
+		{
+			number   = 443
+			protocol = "TCP"
+		},
+	]
+}
+	`
+
 	resourceName := "acl_destination_rule.rule"
 	resource.Test(t, resource.TestCase{
-		PreCheck:          func() { testAccPreCheck(t) },
-		ProviderFactories: testAccProviderFactories,
-		CheckDestroy:      nil,
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		CheckDestroy:             nil,
 		Steps: []resource.TestStep{
 			{
 				Config: `
@@ -247,22 +374,136 @@ resource "acl_destination_rule" "rule" {
 
 	dns = "example.org"
 
-	port {
-		number   = 80
-		protocol = "TCP"
+	port = [
+		{
+			number   = 80
+			protocol = "TCP"
+		},
+		{
+			number   = 443
+			protocol = "TCP"
+		},
+	]
+}
+				`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					testAccResourceExists(resourceName),
+					resource.TestCheckResourceAttr(resourceName, "dns", "example.org"),
+					resource.TestCheckTypeSetElemNestedAttrs(resourceName, "port.*", map[string]string{"protocol": "TCP", "number": "80"}),
+					resource.TestCheckTypeSetElemNestedAttrs(resourceName, "port.*", map[string]string{"protocol": "TCP", "number": "443"}),
+				),
+			},
+			{
+				Config: equivalentConfig,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr(resourceName, "id", "acl::my-acl::my-rule"),
+					resource.TestCheckResourceAttr(resourceName, "dns", "Example.org."),
+					func(s *terraform.State) error {
+						if creates != 1 {
+							return fmt.Errorf("expected the rule to be created once, got %d creations", creates)
+						}
+						return nil
+					},
+				),
+			},
+			{
+				Config:   equivalentConfig,
+				PlanOnly: true,
+			},
+		},
+	})
+}
+
+func TestAccResourceDestinationRuleWaitForSync(t *testing.T) {
+	fakeServer := echo.New()
+	myRule := types.ServiceRule{
+		Rule: types.Rule{
+			RuleID: "my-rule",
+			Destination: types.RuleType{
+				TsuruApp: &types.TsuruAppRule{
+					AppName: "my-destination-app",
+				},
+			},
+		},
+	}
+	expandedRule := types.Rule{
+		RuleID:   "my-rule-my-app",
+		Metadata: map[string]string{"base-ruleid": "my-rule", "app-name": "my-app"},
 	}
+	gets := 0
+
+	fakeServer.Any("/services/acl/proxy/:instance", func(c echo.Context) error {
+		callback := c.QueryParam("callback")
+		if callback == "/rule/"+myRule.RuleID && c.Request().Method == http.MethodDelete {
+			return c.String(http.StatusOK, "")
+		}
+
+		if callback == "/rule" {
+			if c.Request().Method == http.MethodPost {
+				return c.JSON(http.StatusOK, myRule)
+			}
 
-	port {
-		number   = 443
-		protocol = "TCP"
+			// the rule is reported as applied only on the second poll
+			gets++
+			var syncs []types.RuleSyncData
+			if gets > 1 {
+				syncs = append(syncs, types.RuleSyncData{Successful: true})
+			}
+
+			return c.JSON(http.StatusOK, &acl.ServiceRuleData{
+				ServiceInstance: types.ServiceInstance{
+					BindApps: []string{"my-app"},
+					BaseRules: []types.ServiceRule{
+						myRule,
+					},
+				},
+				ExpandedRules: []types.Rule{expandedRule},
+				RulesSync: []types.RuleSyncInfo{
+					{
+						RuleID: expandedRule.RuleID,
+						Syncs:  syncs,
+					},
+				},
+			})
+		}
+		t.Fatalf("method=%q, path=%q, callback=%q, err=\"Not found\"",
+			c.Request().Method,
+			c.Path(),
+			callback,
+		)
+		return c.String(http.StatusNotFound, "")
+	})
+
+	fakeServer.HTTPErrorHandler = func(err error, c echo.Context) {
+		t.Errorf("methods=%s, path=%s, err=%s", c.Request().Method, c.Path(), err.Error())
 	}
+	server := httptest.NewServer(fakeServer)
+	os.Setenv("TSURU_TARGET", server.URL)
+
+	resourceName := "acl_destination_rule.rule"
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		CheckDestroy:             nil,
+		Steps: []resource.TestStep{
+			{
+				Config: `
+resource "acl_destination_rule" "rule" {
+	instance      = "my-acl"
+	wait_for_sync = true
+
+	app = "my-destination-app"
 }
 				`,
 				Check: resource.ComposeAggregateTestCheckFunc(
 					testAccResourceExists(resourceName),
-					resource.TestCheckResourceAttr(resourceName, "dns", "example.org"),
-					resource.TestCheckResourceAttr(resourceName, "port.0.number", "80"),
-					resource.TestCheckResourceAttr(resourceName, "port.1.number", "443"),
+					resource.TestCheckResourceAttr(resourceName, "wait_for_sync", "true"),
+					func(s *terraform.State) error {
+						if gets < 2 {
+							return fmt.Errorf("expected sync status to be polled at least twice, got %d", gets)
+						}
+						return nil
+					},
 				),
 			},
 		},
@@ -271,12 +512,13 @@ resource "acl_destination_rule" "rule" {
 
 func TestAccResourceDestinationRuleIP(t *testing.T) {
 	fakeServer := echo.New()
+	creates := 0
 	myRule := types.ServiceRule{
 		Rule: types.Rule{
 			RuleID: "my-rule",
 			Destination: types.RuleType{
 				ExternalIP: &types.ExternalIPRule{
-					IP: "10.0.0.0/6",
+					IP: "10.0.0.0/8",
 					Ports: []types.ProtoPort{
 						{
 							Protocol: "TCP",
@@ -300,6 +542,7 @@ func TestAccResourceDestinationRuleIP(t *testing.T) {
 
 		if callback == "/rule" {
 			if c.Request().Method == http.MethodPost {
+				creates++
 				return c.JSON(http.StatusOK, myRule)
 			}
 
@@ -325,35 +568,430 @@ func TestAccResourceDestinationRuleIP(t *testing.T) {
 	server := httptest.NewServer(fakeServer)
 	os.Setenv("TSURU_TARGET", server.URL)
 
+	// an equivalent spelling is kept in state without recreating the rule
+	equivalentConfig := `
+resource "acl_destination_rule" "rule" {
+	instance =  "my-acl"
+
+	ip = "10.1.2.3/8"
+
+	port = [
+		{
+			number   = 80
+			protocol = "TCP"
+		},
+		{
+			number   = 443
+			protocol = "TCP"
+		},
+	]
+}
+	`
+
+	resourceName := "acl_destination_rule.rule"
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		CheckDestroy:             nil,
+		Steps: []resource.TestStep{
+			{
+				Config: `
+resource "acl_destination_rule" "rule" {
+	instance =  "my-acl"
+
+	ip = "10.0.0.0/8"
+
+	port = [
+		{
+			number   = 80
+			protocol = "TCP"
+		},
+		{
+			number   = 443
+			protocol = "TCP"
+		},
+	]
+}
+				`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					testAccResourceExists(resourceName),
+					resource.TestCheckResourceAttr(resourceName, "ip", "10.0.0.0/8"),
+					resource.TestCheckTypeSetElemNestedAttrs(resourceName, "port.*", map[string]string{"protocol": "TCP", "number": "80"}),
+					resource.TestCheckTypeSetElemNestedAttrs(resourceName, "port.*", map[string]string{"protocol": "TCP", "number": "443"}),
+				),
+			},
+			{
+				Config: equivalentConfig,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr(resourceName, "id", "acl::my-acl::my-rule"),
+					resource.TestCheckResourceAttr(resourceName, "ip", "10.1.2.3/8"),
+					func(s *terraform.State) error {
+						if creates != 1 {
+							return fmt.Errorf("expected the rule to be created once, got %d creations", creates)
+						}
+						return nil
+					},
+				),
+			},
+			{
+				Config:   equivalentConfig,
+				PlanOnly: true,
+			},
+		},
+	})
+}
+
+func TestAccResourceDestinationRuleUpdatePorts(t *testing.T) {
+	fakeServer := echo.New()
+	var rules []types.ServiceRule
+	nextID := 0
+
+	fakeServer.Any("/services/acl/proxy/:instance", func(c echo.Context) error {
+		callback := c.QueryParam("callback")
+		if strings.HasPrefix(callback, "/rule/") && c.Request().Method == http.MethodDelete {
+			ruleID := strings.TrimPrefix(callback, "/rule/")
+			for i, rule := range rules {
+				if rule.RuleID == ruleID {
+					rules = append(rules[:i], rules[i+1:]...)
+					return c.String(http.StatusOK, "")
+				}
+			}
+			return c.String(http.StatusNotFound, "")
+		}
+
+		if callback == "/rule" {
+			if c.Request().Method == http.MethodPost {
+				var rule types.ServiceRule
+				if err := c.Bind(&rule); err != nil {
+					return err
+				}
+				nextID++
+				rule.RuleID = fmt.Sprintf("my-rule-%d", nextID)
+				rules = append(rules, rule)
+				return c.JSON(http.StatusOK, rule)
+			}
+
+			return c.JSON(http.StatusOK, &acl.ServiceRuleData{
+				ServiceInstance: types.ServiceInstance{
+					BaseRules: rules,
+				},
+			})
+		}
+		t.Fatalf("method=%q, path=%q, callback=%q, err=\"Not found\"",
+			c.Request().Method,
+			c.Path(),
+			callback,
+		)
+		return c.String(http.StatusNotFound, "")
+	})
+
+	fakeServer.HTTPErrorHandler = func(err error, c echo.Context) {
+		t.Errorf("methods=%s, path=%s, err=%s", c.Request().Method, c.Path(), err.Error())
+	}
+	server := httptest.NewServer(fakeServer)
+	os.Setenv("TSURU_TARGET", server.URL)
+
+	// the same ports in another spelling and order keep the current rule
+	lowercaseConfig := `
+resource "acl_destination_rule" "rule" {
+	instance =  "my-acl"
+	name     = "example-org"
+
+	metadata = {
+		repository = "my-infra"
+	}
+
+	dns = "example.org"
+
+	port = [
+		{
+			number   = 443
+			protocol = "tcp"
+		},
+		{
+			number   = 80
+			protocol = "tcp"
+		},
+	]
+}
+	`
+
 	resourceName := "acl_destination_rule.rule"
 	resource.Test(t, resource.TestCase{
-		PreCheck:          func() { testAccPreCheck(t) },
-		ProviderFactories: testAccProviderFactories,
-		CheckDestroy:      nil,
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		CheckDestroy:             nil,
 		Steps: []resource.TestStep{
 			{
 				Config: `
 resource "acl_destination_rule" "rule" {
 	instance =  "my-acl"
+	name     = "example-org"
+
+	metadata = {
+		repository = "my-infra"
+	}
 
-	ip = "10.0.0.0/6"
+	dns = "example.org"
+
+	port = [
+		{
+			number   = 80
+			protocol = "TCP"
+		},
+	]
+}
+				`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					testAccResourceExists(resourceName),
+					resource.TestCheckResourceAttr(resourceName, "id", "acl::my-acl::my-rule-1"),
+					resource.TestCheckResourceAttr(resourceName, "port.#", "1"),
+					resource.TestCheckResourceAttr(resourceName, "name", "example-org"),
+					resource.TestCheckResourceAttr(resourceName, "metadata.repository", "my-infra"),
+				),
+			},
+			{
+				Config: `
+resource "acl_destination_rule" "rule" {
+	instance =  "my-acl"
+	name     = "example-org"
+
+	metadata = {
+		repository = "my-infra"
+	}
+
+	dns = "example.org"
+
+	port = [
+		{
+			number   = 80
+			protocol = "TCP"
+		},
+		{
+			number   = 443
+			protocol = "TCP"
+		},
+	]
+}
+				`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					testAccResourceExists(resourceName),
+					resource.TestCheckResourceAttr(resourceName, "id", "acl::my-acl::my-rule-2"),
+					resource.TestCheckResourceAttr(resourceName, "port.#", "2"),
+					resource.TestCheckTypeSetElemNestedAttrs(resourceName, "port.*", map[string]string{"protocol": "TCP", "number": "443"}),
+					func(s *terraform.State) error {
+						if len(rules) != 1 || rules[0].RuleID != "my-rule-2" {
+							return fmt.Errorf("expected only my-rule-2 to remain, got %v", rules)
+						}
+						return nil
+					},
+				),
+			},
+			{
+				Config: lowercaseConfig,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr(resourceName, "id", "acl::my-acl::my-rule-2"),
+					resource.TestCheckTypeSetElemNestedAttrs(resourceName, "port.*", map[string]string{"protocol": "tcp", "number": "443"}),
+					func(s *terraform.State) error {
+						if nextID != 2 {
+							return fmt.Errorf("expected no new rule to be created, got %d creations", nextID)
+						}
+						return nil
+					},
+				),
+			},
+			{
+				Config:   lowercaseConfig,
+				PlanOnly: true,
+			},
+		},
+	})
+}
+
+func TestAccResourceDestinationRuleAddPorts(t *testing.T) {
+	fakeServer := echo.New()
+	var rules []types.ServiceRule
+	nextID := 0
+
+	fakeServer.Any("/services/acl/proxy/:instance", func(c echo.Context) error {
+		callback := c.QueryParam("callback")
+		if strings.HasPrefix(callback, "/rule/") && c.Request().Method == http.MethodDelete {
+			ruleID := strings.TrimPrefix(callback, "/rule/")
+			for i, rule := range rules {
+				if rule.RuleID == ruleID {
+					rules = append(rules[:i], rules[i+1:]...)
+					return c.String(http.StatusOK, "")
+				}
+			}
+			return c.String(http.StatusNotFound, "")
+		}
+
+		if callback == "/rule" {
+			if c.Request().Method == http.MethodPost {
+				var rule types.ServiceRule
+				if err := c.Bind(&rule); err != nil {
+					return err
+				}
+				// acl-api: a rule without ports equals any rule with the same name
+				for _, existing := range rules {
+					if existing.Destination.ExternalDNS.Name == rule.Destination.ExternalDNS.Name &&
+						(existing.Destination.ExternalDNS.Ports == nil || rule.Destination.ExternalDNS.Ports == nil) {
+						return c.JSON(http.StatusConflict, map[string]string{"message": "rule already exists"})
+					}
+				}
+				nextID++
+				rule.RuleID = fmt.Sprintf("my-rule-%d", nextID)
+				rules = append(rules, rule)
+				return c.JSON(http.StatusOK, rule)
+			}
+
+			return c.JSON(http.StatusOK, &acl.ServiceRuleData{
+				ServiceInstance: types.ServiceInstance{
+					BaseRules: rules,
+				},
+			})
+		}
+		t.Fatalf("method=%q, path=%q, callback=%q, err=\"Not found\"",
+			c.Request().Method,
+			c.Path(),
+			callback,
+		)
+		return c.String(http.StatusNotFound, "")
+	})
 
-	port {
-		number   = 80
-		protocol = "TCP"
+	fakeServer.HTTPErrorHandler = func(err error, c echo.Context) {
+		t.Errorf("methods=%s, path=%s, err=%s", c.Request().Method, c.Path(), err.Error())
 	}
+	server := httptest.NewServer(fakeServer)
+	os.Setenv("TSURU_TARGET", server.URL)
+
+	resourceName := "acl_destination_rule.rule"
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		CheckDestroy:             nil,
+		Steps: []resource.TestStep{
+			{
+				Config: `
+resource "acl_destination_rule" "rule" {
+	instance =  "my-acl"
+	dns      = "example.org"
+}
+				`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					testAccResourceExists(resourceName),
+					resource.TestCheckResourceAttr(resourceName, "id", "acl::my-acl::my-rule-1"),
+					resource.TestCheckResourceAttr(resourceName, "port.#", "0"),
+				),
+			},
+			{
+				Config: `
+resource "acl_destination_rule" "rule" {
+	instance =  "my-acl"
+	dns      = "example.org"
+
+	port = [
+		{
+			number   = 443
+			protocol = "TCP"
+		},
+	]
+}
+				`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					testAccResourceExists(resourceName),
+					resource.TestCheckResourceAttr(resourceName, "id", "acl::my-acl::my-rule-2"),
+					resource.TestCheckResourceAttr(resourceName, "port.#", "1"),
+					resource.TestCheckTypeSetElemNestedAttrs(resourceName, "port.*", map[string]string{"protocol": "TCP", "number": "443"}),
+					func(s *terraform.State) error {
+						if len(rules) != 1 || rules[0].RuleID != "my-rule-2" {
+							return fmt.Errorf("expected only my-rule-2 to remain, got %v", rules)
+						}
+						return nil
+					},
+				),
+			},
+		},
+	})
+}
+
+func TestAccResourceDestinationRulePoolWithPorts(t *testing.T) {
+	fakeServer := echo.New()
+	var rules []types.ServiceRule
+
+	fakeServer.Any("/services/acl/proxy/:instance", func(c echo.Context) error {
+		callback := c.QueryParam("callback")
+		if callback == "/rule/my-rule" && c.Request().Method == http.MethodDelete {
+			rules = nil
+			return c.String(http.StatusOK, "")
+		}
+
+		if callback == "/rule" {
+			if c.Request().Method == http.MethodPost {
+				var rule types.ServiceRule
+				if err := c.Bind(&rule); err != nil {
+					return err
+				}
+				rule.RuleID = "my-rule"
+				rules = append(rules, rule)
+				return c.JSON(http.StatusOK, rule)
+			}
+
+			return c.JSON(http.StatusOK, &acl.ServiceRuleData{
+				ServiceInstance: types.ServiceInstance{
+					BaseRules: rules,
+				},
+			})
+		}
+		t.Fatalf("method=%q, path=%q, callback=%q, err=\"Not found\"",
+			c.Request().Method,
+			c.Path(),
+			callback,
+		)
+		return c.String(http.StatusNotFound, "")
+	})
 
-	port {
-		number   = 443
-		protocol = "TCP"
+	fakeServer.HTTPErrorHandler = func(err error, c echo.Context) {
+		t.Errorf("methods=%s, path=%s, err=%s", c.Request().Method, c.Path(), err.Error())
 	}
+	server := httptest.NewServer(fakeServer)
+	os.Setenv("TSURU_TARGET", server.URL)
+
+	resourceName := "acl_destination_rule.rule"
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		CheckDestroy:             nil,
+		Steps: []resource.TestStep{
+			{
+				// acl-api allows every port of a pool, ports can't be sent
+				Config: `
+resource "acl_destination_rule" "rule" {
+	instance =  "my-acl"
+	pool     = "my-pool"
+
+	port = [
+		{
+			number   = 443
+			protocol = "TCP"
+		},
+	]
+}
+				`,
+				ExpectError: regexp.MustCompile("Invalid Attribute Combination"),
+			},
+			{
+				Config: `
+resource "acl_destination_rule" "rule" {
+	instance =  "my-acl"
+	pool     = "my-pool"
 }
 				`,
 				Check: resource.ComposeAggregateTestCheckFunc(
 					testAccResourceExists(resourceName),
-					resource.TestCheckResourceAttr(resourceName, "ip", "10.0.0.0/6"),
-					resource.TestCheckResourceAttr(resourceName, "port.0.number", "80"),
-					resource.TestCheckResourceAttr(resourceName, "port.1.number", "443"),
+					resource.TestCheckResourceAttr(resourceName, "pool", "my-pool"),
+					resource.TestCheckNoResourceAttr(resourceName, "port"),
+					testAccRuleSetRemoteRules(&rules, 1),
 				),
 			},
 		},
@@ -409,16 +1047,16 @@ func TestAccResourceDestinationRPaaS(t *testing.T) {
 
 	resourceName := "acl_destination_rule.rule"
 	resource.Test(t, resource.TestCase{
-		PreCheck:          func() { testAccPreCheck(t) },
-		ProviderFactories: testAccProviderFactories,
-		CheckDestroy:      nil,
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		CheckDestroy:             nil,
 		Steps: []resource.TestStep{
 			{
 				Config: `
 resource "acl_destination_rule" "rule" {
 	instance =  "my-acl"
 
-	rpaas {
+	rpaas = {
 		service_name = "rpaasv2-be"
 		instance = "my-rpaas"
 	} 
@@ -426,14 +1064,154 @@ resource "acl_destination_rule" "rule" {
 				`,
 				Check: resource.ComposeAggregateTestCheckFunc(
 					testAccResourceExists(resourceName),
-					resource.TestCheckResourceAttr(resourceName, "rpaas.0.service_name", "rpaasv2-be"),
-					resource.TestCheckResourceAttr(resourceName, "rpaas.0.instance", "my-rpaas"),
+					resource.TestCheckResourceAttr(resourceName, "rpaas.service_name", "rpaasv2-be"),
+					resource.TestCheckResourceAttr(resourceName, "rpaas.instance", "my-rpaas"),
+				),
+			},
+		},
+	})
+}
+
+func TestAccResourceDestinationKubernetesService(t *testing.T) {
+	fakeServer := echo.New()
+	myRule := types.ServiceRule{
+		Rule: types.Rule{
+			RuleID: "my-rule",
+			Destination: types.RuleType{
+				KubernetesService: &types.KubernetesServiceRule{
+					Namespace:   "my-namespace",
+					ServiceName: "my-service",
+					ClusterName: "my-cluster",
+				},
+			},
+		},
+	}
+
+	fakeServer.Any("/services/acl/proxy/:instance", func(c echo.Context) error {
+		callback := c.QueryParam("callback")
+		if callback == "/rule/"+myRule.RuleID && c.Request().Method == http.MethodDelete {
+			return c.String(http.StatusOK, "")
+		}
+
+		if callback == "/rule" && c.Request().Method == http.MethodGet {
+			return c.JSON(http.StatusOK, &acl.ServiceRuleData{
+				ServiceInstance: types.ServiceInstance{
+					BaseRules: []types.ServiceRule{
+						myRule,
+					},
+				},
+			})
+		}
+		t.Fatalf("method=%q, path=%q, callback=%q, err=\"Not found\"",
+			c.Request().Method,
+			c.Path(),
+			callback,
+		)
+		return c.String(http.StatusNotFound, "")
+	})
+
+	fakeServer.HTTPErrorHandler = func(err error, c echo.Context) {
+		t.Errorf("methods=%s, path=%s, err=%s", c.Request().Method, c.Path(), err.Error())
+	}
+	server := httptest.NewServer(fakeServer)
+	os.Setenv("TSURU_TARGET", server.URL)
+
+	resourceName := "acl_destination_rule.rule"
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		CheckDestroy:             nil,
+		Steps: []resource.TestStep{
+			{
+				Config: `
+resource "acl_destination_rule" "rule" {
+	instance =  "my-acl"
+
+	kubernetes_service = {
+		namespace    = "my-namespace"
+		service_name = "my-other-service"
+	}
+}
+				`,
+				ExpectError: regexp.MustCompile("kubernetes_service destinations have been deactivated"),
+			},
+			{
+				Config: `
+resource "acl_destination_rule" "rule" {
+	instance =  "my-acl"
+
+	kubernetes_service = {
+		namespace    = "my-namespace"
+		service_name = "my-service"
+		cluster      = "my-cluster"
+	}
+}
+				`,
+				ImportState:        true,
+				ImportStateId:      "acl-rule::acl::my-acl::kubernetes_service::my-namespace::my-service::my-cluster",
+				ImportStatePersist: true,
+				ResourceName:       resourceName,
+			},
+			{
+				Config: `
+resource "acl_destination_rule" "rule" {
+	instance =  "my-acl"
+
+	kubernetes_service = {
+		namespace    = "my-namespace"
+		service_name = "my-service"
+		cluster      = "my-cluster"
+	}
+}
+				`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					testAccResourceExists(resourceName),
+					resource.TestCheckResourceAttr(resourceName, "id", "acl::my-acl::my-rule"),
+					resource.TestCheckResourceAttr(resourceName, "kubernetes_service.namespace", "my-namespace"),
+					resource.TestCheckResourceAttr(resourceName, "kubernetes_service.service_name", "my-service"),
+					resource.TestCheckResourceAttr(resourceName, "kubernetes_service.cluster", "my-cluster"),
 				),
 			},
 		},
 	})
 }
 
+type syncStatusClient struct {
+	acl.Client
+	status acl.RuleSyncStatus
+	calls  int
+}
+
+func (c *syncStatusClient) DestinationRuleSyncStatus(ctx context.Context, ruleID, serviceName, instance string) (*acl.RuleSyncStatus, error) {
+	c.calls++
+	status := c.status
+	return &status, nil
+}
+
+func TestWaitForRuleSync(t *testing.T) {
+	cli := &syncStatusClient{status: acl.RuleSyncStatus{Total: 2, Synced: 2}}
+	require.NoError(t, waitForRuleSync(context.Background(), cli, "acl", "my-acl", "my-rule", time.Minute))
+
+	cli = &syncStatusClient{status: acl.RuleSyncStatus{Total: 2, Synced: 1, Failed: 1, Errors: []string{"my-rule-app2: connection refused"}}}
+	start := time.Now()
+	err := waitForRuleSync(context.Background(), cli, "acl", "my-acl", "my-rule", time.Minute)
+	require.EqualError(t, err, `rule "my-rule" failed to synchronize for 1 of 2 apps: my-rule-app2: connection refused`)
+	require.Equal(t, 1, cli.calls)
+	require.Less(t, time.Since(start), 10*time.Second)
+
+	// no app bound to the instance, nothing to wait for
+	cli = &syncStatusClient{status: acl.RuleSyncStatus{}}
+	require.NoError(t, waitForRuleSync(context.Background(), cli, "acl", "my-acl", "my-rule", time.Minute))
+	require.Equal(t, 1, cli.calls)
+
+	// a bound app without the rule expanded yet
+	cli = &syncStatusClient{status: acl.RuleSyncStatus{Total: 2, Synced: 1}}
+	err = waitForRuleSync(context.Background(), cli, "acl", "my-acl", "my-rule", 2*time.Second)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), `rule "my-rule" synchronized for 1 of 2 apps`)
+	require.Greater(t, cli.calls, 1)
+}
+
 func testAccPreCheck(t *testing.T) {
 	tsuruTarget := os.Getenv("TSURU_TARGET")
 	require.Contains(t, tsuruTarget, "http://127.0.0.1:")
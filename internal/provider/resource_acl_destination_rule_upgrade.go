@@ -0,0 +1,189 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/terraform-plugin-framework-timeouts/resource/timeouts"
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	fwtypes "github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/tsuru/terraform-provider-acl/internal/acl"
+)
+
+// UpgradeState reads the state written by the SDK version of the resource,
+// which was never versioned.
+func (r *destinationRuleResource) UpgradeState(ctx context.Context) map[int64]resource.StateUpgrader {
+	schemaV0 := destinationRuleSchemaV0()
+
+	return map[int64]resource.StateUpgrader{
+		0: {
+			PriorSchema:   &schemaV0,
+			StateUpgrader: upgradeDestinationRuleStateV0,
+		},
+	}
+}
+
+// destinationRuleSchemaV0 is the schema of acl_destination_rule before it was
+// versioned, only used to decode old state.
+func destinationRuleSchemaV0() schema.Schema {
+	return schema.Schema{
+		Attributes: map[string]schema.Attribute{
+			"id": schema.StringAttribute{
+				Computed: true,
+			},
+			"instance": schema.StringAttribute{
+				Required: true,
+			},
+			"service_name": schema.StringAttribute{
+				Optional: true,
+			},
+			"ip": schema.StringAttribute{
+				Optional: true,
+			},
+			"dns": schema.StringAttribute{
+				Optional: true,
+			},
+			"app": schema.StringAttribute{
+				Optional: true,
+			},
+			"pool": schema.StringAttribute{
+				Optional: true,
+			},
+		},
+		Blocks: map[string]schema.Block{
+			"rpaas": schema.ListNestedBlock{
+				NestedObject: schema.NestedBlockObject{
+					Attributes: map[string]schema.Attribute{
+						"service_name": schema.StringAttribute{
+							Optional: true,
+						},
+						"instance": schema.StringAttribute{
+							Optional: true,
+						},
+					},
+				},
+			},
+			"port": schema.ListNestedBlock{
+				NestedObject: schema.NestedBlockObject{
+					Attributes: map[string]schema.Attribute{
+						"protocol": schema.StringAttribute{
+							Required: true,
+						},
+						"number": schema.Int64Attribute{
+							Required: true,
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+type destinationRuleModelV0 struct {
+	ID          fwtypes.String `tfsdk:"id"`
+	Instance    fwtypes.String `tfsdk:"instance"`
+	ServiceName fwtypes.String `tfsdk:"service_name"`
+	IP          fwtypes.String `tfsdk:"ip"`
+	DNS         fwtypes.String `tfsdk:"dns"`
+	App         fwtypes.String `tfsdk:"app"`
+	Pool        fwtypes.String `tfsdk:"pool"`
+	Rpaas       []rpaasModel   `tfsdk:"rpaas"`
+	Port        []portModel    `tfsdk:"port"`
+}
+
+// upgradeDestinationRuleStateV0 rewrites bare rule IDs to
+// <SERVICE>::<INSTANCE>::<RULE_ID> and moves the rpaas and port blocks to
+// nested attributes. The SDK stored unset strings as empty strings, they are
+// null in the current schema.
+func upgradeDestinationRuleStateV0(ctx context.Context, req resource.UpgradeStateRequest, resp *resource.UpgradeStateResponse) {
+	var prior destinationRuleModelV0
+	resp.Diagnostics.Append(req.State.Get(ctx, &prior)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	id, err := upgradeRuleResourceID(prior.ID.ValueString(), prior.ServiceName.ValueString(), prior.Instance.ValueString())
+	if err != nil {
+		resp.Diagnostics.AddError("Unable to Upgrade Resource State", err.Error())
+		return
+	}
+
+	state := destinationRuleResourceModel{
+		ID:          fwtypes.StringValue(id),
+		Instance:    prior.Instance,
+		ServiceName: prior.ServiceName,
+		IP:          stringOrNull(prior.IP),
+		DNS:         stringOrNull(prior.DNS),
+		App:         stringOrNull(prior.App),
+		Pool:        stringOrNull(prior.Pool),
+		Name:        fwtypes.StringNull(),
+		Metadata:    fwtypes.MapNull(fwtypes.StringType),
+		MetadataAll: fwtypes.MapNull(fwtypes.StringType),
+		Creator:     fwtypes.StringNull(),
+		CreatedAt:   fwtypes.StringNull(),
+		WaitForSync: fwtypes.BoolValue(false),
+		Timeouts:    timeouts.Value{Object: fwtypes.ObjectNull(timeoutsAttributeTypes)},
+	}
+	if state.ServiceName.ValueString() == "" {
+		state.ServiceName = fwtypes.StringValue("acl")
+	}
+	if len(prior.Rpaas) > 0 {
+		state.Rpaas = &prior.Rpaas[0]
+	}
+
+	// ports of pool rules were accepted but never sent to acl-api
+	if state.Pool.IsNull() {
+		seen := map[portBlockModel]bool{}
+		for _, port := range prior.Port {
+			port := portBlockModel{
+				Protocol: port.Protocol,
+				Number:   port.Number,
+				From:     fwtypes.Int64Null(),
+				To:       fwtypes.Int64Null(),
+			}
+			if seen[port] {
+				continue
+			}
+			seen[port] = true
+			state.Port = append(state.Port, port)
+		}
+	}
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, state)...)
+}
+
+var timeoutsAttributeTypes = map[string]attr.Type{
+	"create": fwtypes.StringType,
+	"read":   fwtypes.StringType,
+	"update": fwtypes.StringType,
+	"delete": fwtypes.StringType,
+}
+
+func stringOrNull(s fwtypes.String) fwtypes.String {
+	if s.ValueString() == "" {
+		return fwtypes.StringNull()
+	}
+	return s
+}
+
+// upgradeRuleResourceID rewrites bare rule IDs to the
+// <SERVICE>::<INSTANCE>::<RULE_ID> format.
+func upgradeRuleResourceID(id, serviceName, instance string) (string, error) {
+	parts := acl.ParseIDParts(id)
+	switch len(parts) {
+	case 1:
+		if serviceName == "" {
+			serviceName = "acl"
+		}
+		if instance == "" {
+			return "", fmt.Errorf("rule %q has no instance in state", id)
+		}
+		return ruleResourceID(serviceName, instance, parts[0]), nil
+	case 3:
+		return id, nil
+	}
+
+	return "", fmt.Errorf("invalid ID %q, the format must be <SERVICE>::<INSTANCE>::<RULE_ID>", id)
+}
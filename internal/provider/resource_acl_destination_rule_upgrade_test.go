@@ -0,0 +1,173 @@
+// Copyright 2021 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package provider
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	fwtypes "github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-go/tfprotov6"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpgradeRuleResourceID(t *testing.T) {
+	tests := []struct {
+		id          string
+		serviceName string
+		instance    string
+		expected    string
+		err         string
+	}{
+		{
+			id:          "my-rule",
+			serviceName: "acl",
+			instance:    "my-acl",
+			expected:    "acl::my-acl::my-rule",
+		},
+		{
+			id:       "my-rule",
+			instance: "my-acl",
+			expected: "acl::my-acl::my-rule",
+		},
+		{
+			id:          "acl-dev::my-acl::my-rule",
+			serviceName: "acl-dev",
+			instance:    "my-acl",
+			expected:    "acl-dev::my-acl::my-rule",
+		},
+		{
+			id:          "my-rule",
+			serviceName: "acl",
+			err:         `rule "my-rule" has no instance in state`,
+		},
+		{
+			id:       "acl::my-rule",
+			instance: "my-acl",
+			err:      `invalid ID "acl::my-rule", the format must be <SERVICE>::<INSTANCE>::<RULE_ID>`,
+		},
+	}
+
+	for _, tt := range tests {
+		id, err := upgradeRuleResourceID(tt.id, tt.serviceName, tt.instance)
+		if tt.err != "" {
+			require.EqualError(t, err, tt.err)
+			continue
+		}
+		require.NoError(t, err)
+		require.Equal(t, tt.expected, id)
+	}
+}
+
+// upgradeDestinationRuleState runs the state upgrader of version on a raw
+// state, as Terraform stores it.
+func upgradeDestinationRuleState(t *testing.T, version int64, rawState string) destinationRuleResourceModel {
+	ctx := context.Background()
+	upgrader, ok := (&destinationRuleResource{}).UpgradeState(ctx)[version]
+	require.True(t, ok)
+
+	priorState, err := (&tfprotov6.RawState{JSON: []byte(rawState)}).UnmarshalWithOpts(
+		upgrader.PriorSchema.Type().TerraformType(ctx),
+		tfprotov6.UnmarshalOpts{ValueFromJSONOpts: tftypes.ValueFromJSONOpts{IgnoreUndefinedAttributes: true}},
+	)
+	require.NoError(t, err)
+
+	resp := resource.UpgradeStateResponse{
+		State: tfsdk.State{Schema: destinationRuleResourceSchema(ctx)},
+	}
+	upgrader.StateUpgrader(ctx, resource.UpgradeStateRequest{
+		State: &tfsdk.State{Schema: *upgrader.PriorSchema, Raw: priorState},
+	}, &resp)
+	require.False(t, resp.Diagnostics.HasError(), "%v", resp.Diagnostics)
+
+	var model destinationRuleResourceModel
+	diags := resp.State.Get(ctx, &model)
+	require.False(t, diags.HasError(), "%v", diags)
+	return model
+}
+
+func TestUpgradeDestinationRuleStateV0(t *testing.T) {
+	// state written before the schema was versioned
+	model := upgradeDestinationRuleState(t, 0, `{
+		"id": "my-rule",
+		"instance": "my-acl",
+		"service_name": "acl",
+		"ip": "10.0.0.1/24",
+		"dns": "",
+		"app": "",
+		"pool": "",
+		"rpaas": [],
+		"port": [
+			{"protocol": "tcp", "number": 443},
+			{"protocol": "tcp", "number": 443},
+			{"protocol": "udp", "number": 53}
+		]
+	}`)
+
+	require.Equal(t, "acl::my-acl::my-rule", model.ID.ValueString())
+	require.Equal(t, "acl", model.ServiceName.ValueString())
+	require.Equal(t, "10.0.0.1/24", model.IP.ValueString())
+	require.True(t, model.DNS.IsNull())
+	require.True(t, model.App.IsNull())
+	require.True(t, model.Pool.IsNull())
+	require.Nil(t, model.Rpaas)
+	require.True(t, model.Metadata.IsNull())
+	require.False(t, model.WaitForSync.ValueBool())
+	require.ElementsMatch(t, []portBlockModel{
+		{Protocol: fwtypes.StringValue("tcp"), Number: fwtypes.Int64Value(443), From: fwtypes.Int64Null(), To: fwtypes.Int64Null()},
+		{Protocol: fwtypes.StringValue("udp"), Number: fwtypes.Int64Value(53), From: fwtypes.Int64Null(), To: fwtypes.Int64Null()},
+	}, model.Port)
+}
+
+func TestUpgradeDestinationRuleStateV0InvalidID(t *testing.T) {
+	ctx := context.Background()
+	upgrader := (&destinationRuleResource{}).UpgradeState(ctx)[0]
+	priorState, err := (&tfprotov6.RawState{JSON: []byte(`{"id": "my-rule", "service_name": "acl"}`)}).Unmarshal(upgrader.PriorSchema.Type().TerraformType(ctx))
+	require.NoError(t, err)
+
+	resp := resource.UpgradeStateResponse{
+		State: tfsdk.State{Schema: destinationRuleResourceSchema(ctx)},
+	}
+	upgrader.StateUpgrader(ctx, resource.UpgradeStateRequest{
+		State: &tfsdk.State{Schema: *upgrader.PriorSchema, Raw: priorState},
+	}, &resp)
+	require.True(t, resp.Diagnostics.HasError())
+	require.Equal(t, `rule "my-rule" has no instance in state`, resp.Diagnostics.Errors()[0].Detail())
+}
+
+func TestUpgradeDestinationRuleStateV0Destinations(t *testing.T) {
+	model := upgradeDestinationRuleState(t, 0, `{
+		"id": "my-rule",
+		"instance": "my-acl",
+		"service_name": "",
+		"ip": "",
+		"dns": "",
+		"app": "",
+		"pool": "",
+		"rpaas": [{"service_name": "rpaasv2-be", "instance": "my-rpaas"}],
+		"port": []
+	}`)
+	require.Equal(t, "acl::my-acl::my-rule", model.ID.ValueString())
+	require.Equal(t, "acl", model.ServiceName.ValueString())
+	require.Equal(t, &rpaasModel{ServiceName: fwtypes.StringValue("rpaasv2-be"), Instance: fwtypes.StringValue("my-rpaas")}, model.Rpaas)
+	require.Nil(t, model.Port)
+	require.True(t, model.Timeouts.IsNull())
+
+	// ports of pool rules were never sent to acl-api
+	model = upgradeDestinationRuleState(t, 0, `{
+		"id": "acl::my-acl::my-rule",
+		"instance": "my-acl",
+		"service_name": "acl",
+		"pool": "my-pool",
+		"rpaas": [],
+		"port": [{"protocol": "TCP", "number": 443}]
+	}`)
+	require.Equal(t, "acl::my-acl::my-rule", model.ID.ValueString())
+	require.Equal(t, "my-pool", model.Pool.ValueString())
+	require.Nil(t, model.Port)
+}
@@ -1,45 +1,192 @@
 package provider
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
 	"strings"
+	"time"
 
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 	"github.com/tsuru/acl-api/api/types"
+	"github.com/tsuru/terraform-provider-acl/internal/acl"
+	"k8s.io/apimachinery/pkg/util/validation"
 )
 
 func isRetryableError(err error) bool {
-	return strings.Contains(err.Error(), "event locked")
+	return acl.IsLocked(err)
 }
 
-func ruleFromResource(d *schema.ResourceData) *types.Rule {
+func validateDuration(i interface{}, k string) (warnings []string, errors []error) {
+	v, ok := i.(string)
+	if !ok {
+		return nil, []error{fmt.Errorf("expected type of %s to be string", k)}
+	}
+
+	if _, err := time.ParseDuration(v); err != nil {
+		return nil, []error{fmt.Errorf("expected %s to be a valid duration (ex: 500ms, 1s, 1m), got %q", k, v)}
+	}
+
+	return nil, nil
+}
+
+// validateCIDR accepts an IP or a CIDR, warning when the CIDR has host bits set
+// since only its network address is kept.
+func validateCIDR(i interface{}, k string) (warnings []string, errors []error) {
+	v, ok := i.(string)
+	if !ok {
+		return nil, []error{fmt.Errorf("expected type of %s to be string", k)}
+	}
+
+	if net.ParseIP(v) != nil {
+		return nil, nil
+	}
+
+	ip, network, err := net.ParseCIDR(v)
+	if err != nil {
+		return nil, []error{fmt.Errorf("expected %s to be a valid IP or CIDR, got %q", k, v)}
+	}
+
+	if !ip.Equal(network.IP) {
+		warnings = append(warnings, fmt.Sprintf("%s %q has host bits set, it will be stored as %q", k, v, network.String()))
+	}
+
+	return warnings, nil
+}
+
+func normalizeCIDRState(v interface{}) string {
+	return acl.NormalizeCIDR(v.(string))
+}
+
+// validateDNS checks a DNS destination the same way acl-api does, after it is
+// normalized, so mistakes are reported at plan time. Wildcard domains are
+// written as "*.example.org" or ".example.org".
+func validateDNS(i interface{}, k string) (warnings []string, errors []error) {
+	v, ok := i.(string)
+	if !ok {
+		return nil, []error{fmt.Errorf("expected type of %s to be string", k)}
+	}
+
+	if strings.Contains(v, "://") || strings.ContainsAny(v, "/:") {
+		return nil, []error{fmt.Errorf("expected %s to be a hostname without scheme, port or path, got %q", k, v)}
+	}
+
+	name := acl.NormalizeDNS(v)
+	if strings.HasSuffix(name, "cluster.local") {
+		return nil, []error{fmt.Errorf("expected %s to not be a cluster internal address, got %q", k, v)}
+	}
+
+	for _, msg := range validation.IsDNS1123Subdomain(strings.TrimPrefix(name, ".")) {
+		errors = append(errors, fmt.Errorf("expected %s to be a valid DNS name, got %q: %s", k, v, msg))
+	}
+
+	return nil, errors
+}
+
+// validateRuleName rejects names acl-api refuses, which must be DNS-1123
+// subdomains.
+func validateRuleName(i interface{}, k string) (warnings []string, errors []error) {
+	v, ok := i.(string)
+	if !ok {
+		return nil, []error{fmt.Errorf("expected type of %s to be string", k)}
+	}
+
+	for _, msg := range validation.IsDNS1123Subdomain(v) {
+		errors = append(errors, fmt.Errorf("expected %s to be a valid rule name, got %q: %s", k, v, msg))
+	}
+
+	return nil, errors
+}
+
+func normalizeDNSState(v interface{}) string {
+	return acl.NormalizeDNS(v.(string))
+}
+
+// stringValidateFunc adapts a validation function of the SDK resources to
+// the plugin framework resources.
+func stringValidateFunc(description string, validate schema.SchemaValidateFunc) validator.String {
+	return stringValidator{description: description, validate: validate}
+}
+
+type stringValidator struct {
+	description string
+	validate    schema.SchemaValidateFunc
+}
+
+func (v stringValidator) Description(ctx context.Context) string {
+	return v.description
+}
+
+func (v stringValidator) MarkdownDescription(ctx context.Context) string {
+	return v.description
+}
+
+func (v stringValidator) ValidateString(ctx context.Context, req validator.StringRequest, resp *validator.StringResponse) {
+	if req.ConfigValue.IsNull() || req.ConfigValue.IsUnknown() {
+		return
+	}
+
+	warnings, errors := v.validate(req.ConfigValue.ValueString(), req.Path.String())
+	for _, warning := range warnings {
+		resp.Diagnostics.AddAttributeWarning(req.Path, "Attribute Value Warning", warning)
+	}
+	for _, err := range errors {
+		resp.Diagnostics.AddAttributeError(req.Path, "Invalid Attribute Value", err.Error())
+	}
+}
+
+// ruleGetter is satisfied by *schema.ResourceData and ruleAttributes, so rules
+// can be built both from a resource and from a nested block.
+type ruleGetter interface {
+	Get(key string) interface{}
+}
+
+// ruleAttributes holds the attributes of a rule nested in another resource.
+type ruleAttributes map[string]interface{}
+
+func (r ruleAttributes) Get(key string) interface{} {
+	return r[key]
+}
+
+func ruleFromResource(d ruleGetter) *types.Rule {
 	rule := &types.Rule{}
 
-	ports := d.Get("port").([]interface{})
-	var protoPorts []types.ProtoPort
-	for _, port := range ports {
-		portMap := port.(map[string]interface{})
-		proto := portMap["protocol"].(string)
-		port := portMap["number"].(int)
-		protoPorts = append(protoPorts, types.ProtoPort{
-			Protocol: proto,
-			Port:     uint16(port),
-		})
+	var ports []interface{}
+	switch v := d.Get("port").(type) {
+	case []interface{}:
+		ports = v
+	case *schema.Set:
+		ports = v.List()
+	}
+
+	protoPorts := expandProtoPorts(ports)
+
+	rule.RuleName, _ = d.Get("name").(string)
+	if metadata, _ := d.Get("metadata").(map[string]interface{}); len(metadata) > 0 {
+		rule.Metadata = map[string]string{}
+		for key, value := range metadata {
+			rule.Metadata[key] = value.(string)
+		}
 	}
 
 	rule.Destination.TsuruApp = parseTsuruApp(d)
 	rule.Destination.RpaasInstance = parseRpaas(d)
+	rule.Destination.KubernetesService = parseKubernetesService(d)
 
-	dns := d.Get("dns").(string)
+	dns, _ := d.Get("dns").(string)
 	if dns != "" {
 		rule.Destination.ExternalDNS = &types.ExternalDNSRule{
-			Name:  dns,
+			Name:  acl.NormalizeDNS(dns),
 			Ports: protoPorts,
 		}
 	}
-	dstIP := d.Get("ip").(string)
+	dstIP, _ := d.Get("ip").(string)
 	if dstIP != "" {
 		rule.Destination.ExternalIP = &types.ExternalIPRule{
-			IP:    dstIP,
+			IP:    acl.NormalizeCIDR(dstIP),
 			Ports: protoPorts,
 		}
 	}
@@ -47,9 +194,9 @@ func ruleFromResource(d *schema.ResourceData) *types.Rule {
 	return rule
 }
 
-func parseRpaas(d *schema.ResourceData) *types.RpaasInstanceRule {
-	list := d.Get("rpaas").([]interface{})
-	if len(list) == 0 {
+func parseRpaas(d ruleGetter) *types.RpaasInstanceRule {
+	list, _ := d.Get("rpaas").([]interface{})
+	if len(list) == 0 || list[0] == nil {
 		return nil
 	}
 	sourceRpaas := list[0].(map[string]interface{})
@@ -72,6 +219,207 @@ func flattenRpaas(rpaas *types.RpaasInstanceRule) []interface{} {
 	}
 }
 
+func parseKubernetesService(d ruleGetter) *types.KubernetesServiceRule {
+	list, _ := d.Get("kubernetes_service").([]interface{})
+	if len(list) == 0 || list[0] == nil {
+		return nil
+	}
+	service := list[0].(map[string]interface{})
+	return &types.KubernetesServiceRule{
+		Namespace:   service["namespace"].(string),
+		ServiceName: service["service_name"].(string),
+		ClusterName: service["cluster"].(string),
+	}
+}
+
+func flattenKubernetesService(service *types.KubernetesServiceRule) []interface{} {
+	if service == nil {
+		return nil
+	}
+
+	return []interface{}{
+		map[string]interface{}{
+			"namespace":    acl.KubernetesNamespace(service.Namespace),
+			"service_name": service.ServiceName,
+			"cluster":      service.ClusterName,
+		},
+	}
+}
+
+// expandProtoPorts converts port blocks into the list of ports sent to
+// acl-api, a range (from/to) is expanded in one port per number. Protocols are
+// upper cased and repeated ports are sent only once.
+func expandProtoPorts(ports []interface{}) []types.ProtoPort {
+	var protoPorts []types.ProtoPort
+	seen := map[types.ProtoPort]struct{}{}
+	add := func(proto string, number int) {
+		port := types.ProtoPort{
+			Protocol: strings.ToUpper(proto),
+			Port:     uint16(number),
+		}
+		if _, ok := seen[port]; ok {
+			return
+		}
+		seen[port] = struct{}{}
+		protoPorts = append(protoPorts, port)
+	}
+
+	for _, port := range ports {
+		portMap, ok := port.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		proto, _ := portMap["protocol"].(string)
+		number, _ := portMap["number"].(int)
+		from, _ := portMap["from"].(int)
+		to, _ := portMap["to"].(int)
+
+		if number > 0 {
+			add(proto, number)
+			continue
+		}
+
+		for n := from; n > 0 && n <= to; n++ {
+			add(proto, n)
+		}
+	}
+
+	return protoPorts
+}
+
+// flattenPortBlocks returns the port blocks to be stored in state, the
+// current blocks are kept while they expand to the same ports returned by
+// acl-api, otherwise consecutive ports are compressed into ranges.
+func flattenPortBlocks(ports []types.ProtoPort, current []interface{}) []interface{} {
+	if ports == nil {
+		return nil
+	}
+
+	if acl.PortsEqual(expandProtoPorts(current), ports) {
+		return current
+	}
+
+	sorted := expandProtoPorts(flattenProtoPorts(ports))
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Protocol != sorted[j].Protocol {
+			return sorted[i].Protocol < sorted[j].Protocol
+		}
+		return sorted[i].Port < sorted[j].Port
+	})
+
+	var portList []interface{}
+	for i := 0; i < len(sorted); {
+		j := i
+		for j+1 < len(sorted) && sorted[j+1].Protocol == sorted[i].Protocol && sorted[j+1].Port == sorted[j].Port+1 {
+			j++
+		}
+
+		if i == j {
+			portList = append(portList, map[string]interface{}{
+				"protocol": sorted[i].Protocol,
+				"number":   int(sorted[i].Port),
+			})
+		} else {
+			portList = append(portList, map[string]interface{}{
+				"protocol": sorted[i].Protocol,
+				"from":     int(sorted[i].Port),
+				"to":       int(sorted[j].Port),
+			})
+		}
+		i = j + 1
+	}
+
+	return portList
+}
+
+// hashPortBlock identifies a port block inside a set regardless of the
+// protocol case, so "tcp" and "TCP" never show up as a change.
+func hashPortBlock(v interface{}) int {
+	port := v.(map[string]interface{})
+	proto, _ := port["protocol"].(string)
+	number, _ := port["number"].(int)
+	from, _ := port["from"].(int)
+	to, _ := port["to"].(int)
+	if number > 0 {
+		return schema.HashString(fmt.Sprintf("%s:%d", strings.ToUpper(proto), number))
+	}
+	return schema.HashString(fmt.Sprintf("%s:%d-%d", strings.ToUpper(proto), from, to))
+}
+
+func suppressProtocolCase(k, old, new string, d *schema.ResourceData) bool {
+	return strings.EqualFold(old, new)
+}
+
+// errPortsNotSupported is returned when ports are set for a destination that
+// acl-api can't restrict by port: tsuru apps, pools, rpaas instances and
+// kubernetes services always allow every port.
+var errPortsNotSupported = errors.New("port is only supported for ip and dns destinations, acl-api allows every port of app, pool, rpaas and kubernetes_service destinations")
+
+// errKubernetesServiceDeactivated is returned when a new kubernetes_service
+// rule is planned, acl-api rejects them since they were deactivated. Existing
+// rules can still be imported, read and removed.
+var errKubernetesServiceDeactivated = errors.New("kubernetes_service destinations have been deactivated for use in acl-api, please use instead app or rpaas destinations")
+
+// maxRulePorts limits how many ports a rule expands to, each port of a range
+// is sent to acl-api on its own.
+const maxRulePorts = 1024
+
+// validatePortBlocks checks that each port block sets either a number or a
+// range, that ranges are ordered and that no port is declared twice. known is
+// false while some port values are unknown until apply, blocks setting
+// neither a number nor a range are then skipped.
+func validatePortBlocks(ports []interface{}, known bool) error {
+	type portRange struct{ from, to int }
+	declared := map[string][]portRange{}
+	total := 0
+
+	for _, port := range ports {
+		portMap, ok := port.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		proto, _ := portMap["protocol"].(string)
+		number, _ := portMap["number"].(int)
+		from, _ := portMap["from"].(int)
+		to, _ := portMap["to"].(int)
+
+		proto = strings.ToUpper(proto)
+		if number > 0 && (from > 0 || to > 0) {
+			return fmt.Errorf("port %s %d: number conflicts with from/to", proto, number)
+		}
+		if number == 0 && (from == 0) != (to == 0) {
+			return fmt.Errorf("port %s: from and to must be set together", proto)
+		}
+		if number == 0 && from == 0 {
+			if known {
+				// an empty port list allows every port
+				return fmt.Errorf("port %s: number or from/to must be set", proto)
+			}
+			continue
+		}
+		if number > 0 {
+			from, to = number, number
+		}
+		if from > to {
+			return fmt.Errorf("port %s %d-%d: from must not be greater than to", proto, from, to)
+		}
+
+		for _, r := range declared[proto] {
+			if from <= r.to && r.from <= to {
+				return fmt.Errorf("port %s %d-%d: overlaps with %d-%d", proto, from, to, r.from, r.to)
+			}
+		}
+		declared[proto] = append(declared[proto], portRange{from: from, to: to})
+
+		total += to - from + 1
+		if total > maxRulePorts {
+			return fmt.Errorf("port %s %d-%d: a rule can have at most %d ports, split it in more rules", proto, from, to, maxRulePorts)
+		}
+	}
+
+	return nil
+}
+
 func flattenProtoPorts(ports []types.ProtoPort) []interface{} {
 	if ports == nil {
 		return nil
@@ -88,7 +436,74 @@ func flattenProtoPorts(ports []types.ProtoPort) []interface{} {
 	return portList
 }
 
-func parseTsuruApp(d *schema.ResourceData) *types.TsuruAppRule {
+func flattenRule(rule *types.Rule) map[string]interface{} {
+	ruleMap := flattenRuleDestination(rule)
+	ruleMap["rule_id"] = rule.RuleID
+	ruleMap["type"] = acl.RuleDestinationType(rule)
+	ruleMap["name"] = rule.RuleName
+	ruleMap["metadata"] = rule.Metadata
+	ruleMap["creator"] = rule.Creator
+	ruleMap["created_at"] = flattenTime(rule.Created)
+	ruleMap["removed"] = rule.Removed
+	return ruleMap
+}
+
+// resourceMetadata returns the rule metadata without the keys of the provider
+// default_metadata, unless they are also set on the resource. Keys are
+// stripped whatever their value, so changing a default doesn't show up as a
+// change, and a replacement, of every rule.
+func resourceMetadata(configured map[string]interface{}, all, defaults map[string]string) map[string]string {
+	metadata := map[string]string{}
+	for key, value := range all {
+		_, isConfigured := configured[key]
+		_, isDefault := defaults[key]
+		if isDefault && !isConfigured {
+			continue
+		}
+		metadata[key] = value
+	}
+	return metadata
+}
+
+func flattenTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+func flattenRuleDestination(rule *types.Rule) map[string]interface{} {
+	ruleMap := map[string]interface{}{}
+
+	switch acl.RuleDestinationType(rule) {
+	case acl.DestinationApp:
+		ruleMap["app"] = rule.Destination.TsuruApp.AppName
+	case acl.DestinationPool:
+		ruleMap["pool"] = rule.Destination.TsuruApp.PoolName
+	}
+
+	if rule.Destination.ExternalIP != nil {
+		ruleMap["ip"] = acl.NormalizeCIDR(rule.Destination.ExternalIP.IP)
+		ruleMap["port"] = flattenProtoPorts(rule.Destination.ExternalIP.Ports)
+	}
+
+	if rule.Destination.ExternalDNS != nil {
+		ruleMap["dns"] = acl.NormalizeDNS(rule.Destination.ExternalDNS.Name)
+		ruleMap["port"] = flattenProtoPorts(rule.Destination.ExternalDNS.Ports)
+	}
+
+	if rule.Destination.RpaasInstance != nil {
+		ruleMap["rpaas"] = flattenRpaas(rule.Destination.RpaasInstance)
+	}
+
+	if rule.Destination.KubernetesService != nil {
+		ruleMap["kubernetes_service"] = flattenKubernetesService(rule.Destination.KubernetesService)
+	}
+
+	return ruleMap
+}
+
+func parseTsuruApp(d ruleGetter) *types.TsuruAppRule {
 	app, _ := d.Get("app").(string)
 	pool, _ := d.Get("pool").(string)
 	if app == "" && pool == "" {
@@ -126,3 +541,153 @@ func rpaasSchema(baseName string) *schema.Resource {
 		},
 	}
 }
+
+func kubernetesServiceSchema() *schema.Resource {
+	return &schema.Resource{
+		Schema: map[string]*schema.Schema{
+			"namespace": {
+				Type:        schema.TypeString,
+				Description: "Destination kubernetes namespace",
+				Optional:    true,
+				ForceNew:    true,
+				Default:     "default",
+			},
+			"service_name": {
+				Type:        schema.TypeString,
+				Description: "Destination kubernetes service name",
+				Required:    true,
+				ForceNew:    true,
+			},
+			"cluster": {
+				Type:        schema.TypeString,
+				Description: "Destination kubernetes cluster name",
+				Optional:    true,
+				ForceNew:    true,
+			},
+		},
+	}
+}
+
+// destinationRuleDataSchema describes a rule as exposed by data sources.
+func destinationRuleDataSchema() map[string]*schema.Schema {
+	return map[string]*schema.Schema{
+		"rule_id": {
+			Type:        schema.TypeString,
+			Computed:    true,
+			Description: "Rule ID",
+		},
+		"type": {
+			Type:        schema.TypeString,
+			Computed:    true,
+			Description: "Destination type (app, pool, rpaas, ip, dns, kubernetes_service)",
+		},
+		"name": {
+			Type:        schema.TypeString,
+			Computed:    true,
+			Description: "Rule name",
+		},
+		"metadata": {
+			Type:        schema.TypeMap,
+			Computed:    true,
+			Elem:        &schema.Schema{Type: schema.TypeString},
+			Description: "Rule metadata",
+		},
+		"creator": {
+			Type:        schema.TypeString,
+			Computed:    true,
+			Description: "User who created the rule",
+		},
+		"created_at": {
+			Type:        schema.TypeString,
+			Computed:    true,
+			Description: "Rule creation time (RFC3339)",
+		},
+		"removed": {
+			Type:        schema.TypeBool,
+			Computed:    true,
+			Description: "Whether the rule was removed",
+		},
+		"app": {
+			Type:        schema.TypeString,
+			Computed:    true,
+			Description: "Destination tsuru app name",
+		},
+		"pool": {
+			Type:        schema.TypeString,
+			Computed:    true,
+			Description: "Tsuru Pool name",
+		},
+		"ip": {
+			Type:        schema.TypeString,
+			Computed:    true,
+			Description: "Destination IP address",
+		},
+		"dns": {
+			Type:        schema.TypeString,
+			Computed:    true,
+			Description: "Destination fully qualified domain name (FQDN)",
+		},
+		"rpaas": {
+			Type:        schema.TypeList,
+			Computed:    true,
+			Description: "Destination tsuru rpaas name",
+			Elem: &schema.Resource{
+				Schema: map[string]*schema.Schema{
+					"service_name": {
+						Type:        schema.TypeString,
+						Computed:    true,
+						Description: "Destination rpaas service name",
+					},
+					"instance": {
+						Type:        schema.TypeString,
+						Computed:    true,
+						Description: "Destination rpaas instance name",
+					},
+				},
+			},
+		},
+		"kubernetes_service": {
+			Type:        schema.TypeList,
+			Computed:    true,
+			Description: "Destination kubernetes service",
+			Elem: &schema.Resource{
+				Schema: map[string]*schema.Schema{
+					"namespace": {
+						Type:        schema.TypeString,
+						Computed:    true,
+						Description: "Destination kubernetes namespace",
+					},
+					"service_name": {
+						Type:        schema.TypeString,
+						Computed:    true,
+						Description: "Destination kubernetes service name",
+					},
+					"cluster": {
+						Type:        schema.TypeString,
+						Computed:    true,
+						Description: "Destination kubernetes cluster name",
+					},
+				},
+			},
+		},
+		"port": {
+			Type:        schema.TypeList,
+			Computed:    true,
+			Description: "Destination port and protocol list",
+			Elem: &schema.Resource{
+				Schema: map[string]*schema.Schema{
+					"protocol": {
+						Type:        schema.TypeString,
+						Computed:    true,
+						Description: "Procotol name",
+					},
+					"number": {
+						Type:        schema.TypeInt,
+						Computed:    true,
+						Description: "Port number",
+					},
+				},
+			},
+		},
+	}
+}
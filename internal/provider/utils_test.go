@@ -0,0 +1,204 @@
+// Copyright 2021 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package provider
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tsuru/acl-api/api/types"
+)
+
+func TestExpandProtoPorts(t *testing.T) {
+	ports := expandProtoPorts([]interface{}{
+		map[string]interface{}{"protocol": "TCP", "number": 80, "from": 0, "to": 0},
+		map[string]interface{}{"protocol": "TCP", "number": 0, "from": 30000, "to": 30002},
+		map[string]interface{}{"protocol": "tcp", "number": 80, "from": 0, "to": 0},
+		map[string]interface{}{"protocol": "udp", "number": 53, "from": 0, "to": 0},
+	})
+
+	require.Equal(t, []types.ProtoPort{
+		{Protocol: "TCP", Port: 80},
+		{Protocol: "TCP", Port: 30000},
+		{Protocol: "TCP", Port: 30001},
+		{Protocol: "TCP", Port: 30002},
+		{Protocol: "UDP", Port: 53},
+	}, ports)
+}
+
+func TestHashPortBlock(t *testing.T) {
+	require.Equal(t,
+		hashPortBlock(map[string]interface{}{"protocol": "TCP", "number": 80, "from": 0, "to": 0}),
+		hashPortBlock(map[string]interface{}{"protocol": "tcp", "number": 80, "from": 0, "to": 0}),
+	)
+	require.NotEqual(t,
+		hashPortBlock(map[string]interface{}{"protocol": "TCP", "number": 80, "from": 0, "to": 0}),
+		hashPortBlock(map[string]interface{}{"protocol": "UDP", "number": 80, "from": 0, "to": 0}),
+	)
+	require.NotEqual(t,
+		hashPortBlock(map[string]interface{}{"protocol": "TCP", "number": 0, "from": 80, "to": 81}),
+		hashPortBlock(map[string]interface{}{"protocol": "TCP", "number": 0, "from": 80, "to": 82}),
+	)
+}
+
+func TestFlattenPortBlocks(t *testing.T) {
+	current := []interface{}{
+		map[string]interface{}{"protocol": "TCP", "number": 443, "from": 0, "to": 0},
+		map[string]interface{}{"protocol": "TCP", "number": 0, "from": 30000, "to": 30002},
+	}
+	ports := []types.ProtoPort{
+		{Protocol: "TCP", Port: 30000},
+		{Protocol: "TCP", Port: 30001},
+		{Protocol: "TCP", Port: 30002},
+		{Protocol: "TCP", Port: 443},
+	}
+
+	require.Equal(t, current, flattenPortBlocks(ports, current))
+
+	// acl-api may return ports with another case, order or repeated
+	require.Equal(t, current, flattenPortBlocks([]types.ProtoPort{
+		{Protocol: "tcp", Port: 443},
+		{Protocol: "TCP", Port: 30002},
+		{Protocol: "TCP", Port: 30001},
+		{Protocol: "TCP", Port: 30000},
+		{Protocol: "TCP", Port: 443},
+	}, current))
+
+	require.Equal(t, []interface{}{
+		map[string]interface{}{"protocol": "TCP", "number": 443},
+		map[string]interface{}{"protocol": "TCP", "from": 30000, "to": 30002},
+		map[string]interface{}{"protocol": "UDP", "number": 30001},
+	}, flattenPortBlocks(append(ports, types.ProtoPort{Protocol: "UDP", Port: 30001}), current))
+}
+
+func TestValidatePortBlocks(t *testing.T) {
+	tests := []struct {
+		ports   []interface{}
+		unknown bool
+		err     string
+	}{
+		{
+			ports: []interface{}{
+				map[string]interface{}{"protocol": "TCP", "number": 21},
+				map[string]interface{}{"protocol": "TCP", "from": 30000, "to": 30100},
+				map[string]interface{}{"protocol": "UDP", "from": 30000, "to": 30100},
+			},
+		},
+		{
+			ports: []interface{}{
+				map[string]interface{}{"protocol": "TCP", "number": 21, "from": 30000, "to": 30100},
+			},
+			err: "port TCP 21: number conflicts with from/to",
+		},
+		{
+			ports: []interface{}{
+				map[string]interface{}{"protocol": "TCP", "from": 30000},
+			},
+			err: "port TCP: from and to must be set together",
+		},
+		{
+			ports: []interface{}{
+				map[string]interface{}{"protocol": "TCP", "from": 30100, "to": 30000},
+			},
+			err: "port TCP 30100-30000: from must not be greater than to",
+		},
+		{
+			ports: []interface{}{
+				map[string]interface{}{"protocol": "TCP", "from": 30000, "to": 30100},
+				map[string]interface{}{"protocol": "tcp", "number": 30050},
+			},
+			err: "port TCP 30050-30050: overlaps with 30000-30100",
+		},
+		{
+			ports: []interface{}{
+				map[string]interface{}{"protocol": "TCP"},
+			},
+			err: "port TCP: number or from/to must be set",
+		},
+		{
+			ports: []interface{}{
+				map[string]interface{}{"protocol": "TCP", "number": 0, "from": 0, "to": 0},
+			},
+			unknown: true,
+		},
+		{
+			ports: []interface{}{
+				map[string]interface{}{"protocol": "TCP", "from": 1, "to": 65535},
+			},
+			err: "port TCP 1-65535: a rule can have at most 1024 ports, split it in more rules",
+		},
+		{
+			ports: []interface{}{
+				map[string]interface{}{"protocol": "TCP", "from": 30000, "to": 30999},
+				map[string]interface{}{"protocol": "UDP", "from": 30000, "to": 30099},
+			},
+			err: "port UDP 30000-30099: a rule can have at most 1024 ports, split it in more rules",
+		},
+	}
+
+	for _, tt := range tests {
+		err := validatePortBlocks(tt.ports, !tt.unknown)
+		if tt.err == "" {
+			require.NoError(t, err)
+			continue
+		}
+		require.EqualError(t, err, tt.err)
+	}
+}
+
+func TestValidateCIDR(t *testing.T) {
+	warnings, errors := validateCIDR("10.0.0.1", "ip")
+	require.Empty(t, warnings)
+	require.Empty(t, errors)
+
+	warnings, errors = validateCIDR("10.0.0.0/24", "ip")
+	require.Empty(t, warnings)
+	require.Empty(t, errors)
+
+	warnings, errors = validateCIDR("10.0.0.1/24", "ip")
+	require.Equal(t, []string{`ip "10.0.0.1/24" has host bits set, it will be stored as "10.0.0.0/24"`}, warnings)
+	require.Empty(t, errors)
+
+	_, errors = validateCIDR("10.0.0.0/33", "ip")
+	require.Len(t, errors, 1)
+	require.EqualError(t, errors[0], `expected ip to be a valid IP or CIDR, got "10.0.0.0/33"`)
+}
+
+func TestValidateDNS(t *testing.T) {
+	for _, name := range []string{"example.org", "Example.org.", "*.example.org", ".example.org"} {
+		_, errors := validateDNS(name, "dns")
+		require.Empty(t, errors, name)
+	}
+
+	tests := map[string]string{
+		"https://example.org":  `expected dns to be a hostname without scheme, port or path, got "https://example.org"`,
+		"example.org/path":     `expected dns to be a hostname without scheme, port or path, got "example.org/path"`,
+		"example.org:443":      `expected dns to be a hostname without scheme, port or path, got "example.org:443"`,
+		"my-svc.cluster.local": `expected dns to not be a cluster internal address, got "my-svc.cluster.local"`,
+	}
+	for name, expected := range tests {
+		_, errors := validateDNS(name, "dns")
+		require.Len(t, errors, 1, name)
+		require.EqualError(t, errors[0], expected)
+	}
+
+	_, errors := validateDNS("example_org", "dns")
+	require.NotEmpty(t, errors)
+	_, errors = validateDNS("foo.*.example.org", "dns")
+	require.NotEmpty(t, errors)
+}
+
+func TestValidateRuleName(t *testing.T) {
+	for _, name := range []string{"example-org", "example.org", "rule1"} {
+		_, errors := validateRuleName(name, "name")
+		require.Empty(t, errors, name)
+	}
+
+	for _, name := range []string{"Example-Org", "example_org", "-example", "example org", ""} {
+		_, errors := validateRuleName(name, "name")
+		require.NotEmpty(t, errors, name)
+		require.Contains(t, errors[0].Error(), "expected name to be a valid rule name")
+	}
+}
@@ -7,8 +7,9 @@ import (
 	"context"
 	"flag"
 	"log"
+	"os"
 
-	"github.com/hashicorp/terraform-plugin-sdk/v2/plugin"
+	"github.com/hashicorp/terraform-plugin-go/tfprotov6/tf6server"
 	"github.com/tsuru/terraform-provider-acl/internal/provider"
 )
 
@@ -22,20 +23,30 @@ var (
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "generate" {
+		if err := runGenerate(context.Background(), os.Args[2:], os.Stdout); err != nil && err != flag.ErrHelp {
+			log.Fatal(err.Error())
+		}
+		return
+	}
+
 	var debugMode bool
 	flag.BoolVar(&debugMode, "debug", false, "set to true to run the provider with support for debuggers like delve")
 	flag.Parse()
 
-	opts := &plugin.ServeOpts{ProviderFunc: provider.Provider}
+	ctx := context.Background()
+	providerServer, err := provider.NewProviderServer(ctx, version)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
 
+	var serveOpts []tf6server.ServeOpt
 	if debugMode {
-		// TODO: update this string with the full name of your provider as used in your configs
-		err := plugin.Debug(context.Background(), "registry.terraform.io/tsuru/acl", opts)
-		if err != nil {
-			log.Fatal(err.Error())
-		}
-		return
+		serveOpts = append(serveOpts, tf6server.WithManagedDebug())
 	}
 
-	plugin.Serve(opts)
+	err = tf6server.Serve("registry.terraform.io/tsuru/acl", providerServer, serveOpts...)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
 }